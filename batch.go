@@ -0,0 +1,212 @@
+package hashedrpz
+
+// Batch hashing, fanning out the hashing of many domainnames over multiple workers
+// while keeping the results in the order of the input.
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// BatchOptions configures HashBatch and HashStream.
+type BatchOptions struct {
+	// Workers is the number of goroutines hashing in parallel,
+	// when 0 or less it defaults to runtime.GOMAXPROCS(0).
+	Workers int
+
+	// Wildcard causes HashWildcard to be used instead of Hash,
+	// thus too long domains are encoded as a wildcard instead of returning ErrTooLong.
+	Wildcard bool
+
+	// Collapse causes HashCollapsed to be used instead of Hash (ignored with Wildcard),
+	// thus too long domains get a collapsed label instead of returning ErrTooLong.
+	Collapse bool
+}
+
+// workers returns the number of workers to use
+func (o BatchOptions) workers() int {
+	if o.Workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+
+	return o.Workers
+}
+
+// BatchResult is the result of hashing a single lefthandside from a batch.
+type BatchResult struct {
+	// Index is the position of the lefthandside in the input (counting from 0)
+	Index int
+
+	// Input is the lefthandside that was hashed
+	Input string
+
+	// Output is the hashed result, as returned by Hash or HashWildcard
+	Output string
+
+	// IsWildcard indicates that the output was made a wildcard (only with BatchOptions.Wildcard)
+	IsWildcard bool
+
+	// IsCollapsed indicates that the output has a collapsed label (only with BatchOptions.Collapse)
+	IsCollapsed bool
+
+	// Err is the error for this specific item (e.g. ErrTooLong or ErrWildcardNotAtStart)
+	Err error
+}
+
+// hashResult hashes r.Input and stores the outcome in r
+func (h *HashedRPZ) hashResult(r *BatchResult, origindomain string, opts BatchOptions) {
+	if opts.Wildcard {
+		r.Output, r.IsWildcard, r.Err = h.HashWildcard(r.Input, origindomain, NoCallback)
+	} else if opts.Collapse {
+		r.Output, r.IsCollapsed, r.Err = h.HashCollapsed(r.Input, origindomain, NoCallback)
+	} else {
+		r.Output, r.Err = h.Hash(r.Input, origindomain, NoCallback)
+	}
+
+	return
+}
+
+// HashBatch hashes all the lefthandsides using multiple workers and returns
+// the results in the same order as the input, thus results[i] belongs to lefthandsides[i].
+//
+// Errors for individual items are returned in BatchResult.Err, the returned
+// error is only set when the context got cancelled before all items were hashed;
+// in that case the items that were not hashed have their Err set to the error of the context.
+func (h *HashedRPZ) HashBatch(ctx context.Context, lefthandsides []string, origindomain string, opts BatchOptions) (results []BatchResult, err error) {
+	results = make([]BatchResult, len(lefthandsides))
+
+	// next is the last index claimed by a worker
+	next := int64(-1)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < opts.workers(); w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for ctx.Err() == nil {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(lefthandsides) {
+					return
+				}
+
+				r := &results[i]
+				r.Index = i
+				r.Input = lefthandsides[i]
+
+				h.hashResult(r, origindomain, opts)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// Every claimed item got hashed, thus the ones after it were skipped because of the context
+	for i := int(next) + 1; i < len(lefthandsides); i++ {
+		err = ctx.Err()
+		results[i] = BatchResult{Index: i, Input: lefthandsides[i], Err: err}
+	}
+
+	return
+}
+
+// batchJob is a single item being hashed by HashStream
+type batchJob struct {
+	result BatchResult
+
+	// done is closed when result has been filled in
+	done chan struct{}
+}
+
+// HashStream hashes the lefthandsides received from the input channel using
+// multiple workers and sends the results, in the same order as they were
+// received, on the returned channel.
+//
+// The returned channel is closed after the input channel has been closed and
+// all results have been sent, or when the context got cancelled; the caller
+// should thus check ctx.Err() after the returned channel got closed.
+//
+// Errors for individual items are returned in BatchResult.Err.
+func (h *HashedRPZ) HashStream(ctx context.Context, lefthandsides <-chan string, origindomain string, opts BatchOptions) <-chan BatchResult {
+	workers := opts.workers()
+
+	out := make(chan BatchResult, workers)
+
+	// jobs are picked up by the workers
+	jobs := make(chan *batchJob, workers)
+
+	// pending keeps the jobs in input order, the size limits how far ahead the workers can go
+	pending := make(chan *batchJob, workers*2)
+
+	// The dispatcher reads the input and hands out the jobs
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+
+		for i := 0; ; i++ {
+			var (
+				lhs string
+				ok  bool
+			)
+
+			select {
+			case <-ctx.Done():
+				return
+			case lhs, ok = <-lefthandsides:
+				if !ok {
+					return
+				}
+			}
+
+			j := &batchJob{result: BatchResult{Index: i, Input: lhs}, done: make(chan struct{})}
+
+			// First queue it as pending, so that the order is kept
+			select {
+			case <-ctx.Done():
+				return
+			case pending <- j:
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- j:
+			}
+		}
+	}()
+
+	// The workers hash till there are no more jobs
+	for w := 0; w < workers; w++ {
+		go func() {
+			for j := range jobs {
+				h.hashResult(&j.result, origindomain, opts)
+				close(j.done)
+			}
+		}()
+	}
+
+	// The collector sends the results in the order of the input
+	go func() {
+		defer close(out)
+
+		for j := range pending {
+			select {
+			case <-ctx.Done():
+				return
+			case <-j.done:
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- j.result:
+			}
+		}
+	}()
+
+	return out
+}
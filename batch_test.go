@@ -0,0 +1,186 @@
+package hashedrpz
+
+// Tests for the batch hashing functions
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// checkBatchResult verifies that a BatchResult matches the test
+func checkBatchResult(t *testing.T, i int, r BatchResult, wildcard bool) {
+	tt := tests[i]
+
+	if r.Index != i || r.Input != tt.Input {
+		t.Errorf("Expected result %d for %q but got %d for %q", i, tt.Input, r.Index, r.Input)
+		return
+	}
+
+	experr := tt.Error
+	if wildcard {
+		experr = tt.ErrorWildcard
+	}
+
+	if !errors.Is(r.Err, experr) {
+		t.Errorf("Expected error %s for %q but got: %s", experr, tt.Input, r.Err)
+		return
+	}
+
+	if r.Err == nil && r.Output != tt.Output {
+		t.Errorf("Expected output %q for %q but got: %q", tt.Output, tt.Input, r.Output)
+		return
+	}
+
+	return
+}
+
+// testInputs returns the inputs of the tests
+func testInputs() (inputs []string) {
+	for _, tt := range tests {
+		inputs = append(inputs, tt.Input)
+	}
+
+	return
+}
+
+// TestHashBatch checks that HashBatch returns the same as Hash in the order of the input
+func TestHashBatch(t *testing.T) {
+	h := New(testkey)
+
+	for _, wildcard := range []bool{false, true} {
+		results, err := h.HashBatch(context.Background(), testInputs(), origindomain, BatchOptions{Workers: 3, Wildcard: wildcard})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(results) != len(tests) {
+			t.Fatalf("Expected %d results, got %d", len(tests), len(results))
+		}
+
+		for i, r := range results {
+			checkBatchResult(t, i, r, wildcard)
+		}
+	}
+
+	return
+}
+
+// TestHashBatchCollapse checks that HashBatch with Collapse returns the same as HashCollapsed
+func TestHashBatchCollapse(t *testing.T) {
+	h := New(testkey)
+
+	results, err := h.HashBatch(context.Background(), testInputs(), origindomain, BatchOptions{Workers: 3, Collapse: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for i, r := range results {
+		exp, collapsed, experr := h.HashCollapsed(tests[i].Input, origindomain, NoCallback)
+
+		if r.Output != exp || r.IsCollapsed != collapsed || !sameError(r.Err, experr) {
+			t.Errorf("Expected %q (%t, %v) for %q but got: %q (%t, %v)", exp, collapsed, experr, r.Input, r.Output, r.IsCollapsed, r.Err)
+		}
+	}
+
+	return
+}
+
+// TestHashBatchCancel checks that a cancelled context is honoured
+func TestHashBatchCancel(t *testing.T) {
+	h := New(testkey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := h.HashBatch(ctx, testInputs(), origindomain, BatchOptions{})
+	if err != context.Canceled {
+		t.Fatalf("Expected error %s but got: %s", context.Canceled, err)
+	}
+
+	for i, r := range results {
+		if r.Index != i || r.Err != context.Canceled {
+			t.Errorf("Expected item %d to be cancelled, got %d with error %s", i, r.Index, r.Err)
+		}
+	}
+
+	return
+}
+
+// TestHashStream checks that HashStream returns the same as Hash in the order of the input
+func TestHashStream(t *testing.T) {
+	h := New(testkey)
+
+	in := make(chan string)
+
+	go func() {
+		defer close(in)
+
+		// Repeat the tests, so that the workers have plenty to reorder
+		for n := 0; n < 10; n++ {
+			for _, tt := range tests {
+				in <- tt.Input
+			}
+		}
+	}()
+
+	n := 0
+	for r := range h.HashStream(context.Background(), in, origindomain, BatchOptions{Workers: 4}) {
+		if r.Index != n {
+			t.Fatalf("Expected result %d, got %d", n, r.Index)
+		}
+
+		r.Index %= len(tests)
+		checkBatchResult(t, r.Index, r, false)
+
+		n++
+	}
+
+	if n != 10*len(tests) {
+		t.Errorf("Expected %d results, got %d", 10*len(tests), n)
+	}
+
+	return
+}
+
+// TestHashStreamCancel checks that HashStream stops when the context is cancelled
+func TestHashStreamCancel(t *testing.T) {
+	h := New(testkey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Never closed, thus only the cancel can stop the stream
+	in := make(chan string)
+
+	out := h.HashStream(ctx, in, origindomain, BatchOptions{Workers: 2})
+
+	in <- "www.example.com"
+
+	r := <-out
+	if r.Err != nil || r.Output != "qtr7pq8.slhf50h8dgst0.8r4m02g" {
+		t.Errorf("Unexpected result %q error %s", r.Output, r.Err)
+	}
+
+	cancel()
+
+	for r := range out {
+		t.Errorf("Unexpected result after cancel: %q", r.Input)
+	}
+
+	return
+}
+
+// BenchmarkHashBatch hashes the tests as a batch
+func BenchmarkHashBatch(b *testing.B) {
+	h := New("teststring: Yl4pC2Qn s8HdV0aZ fW3kTj6M rB9eUq1X gN5oLc7I hP2vKm8S xD4tEa6R jQ0wZb3F")
+
+	inputs := testInputs()
+
+	for i := 0; i < b.N; i += len(inputs) {
+		h.HashBatch(context.Background(), inputs, origindomain, BatchOptions{})
+
+		// We ignore error checking, this is about speed ;)
+	}
+
+	return
+}
@@ -20,6 +20,9 @@
 #define lengthof(arr) ((uint64_t)(sizeof(arr)/sizeof(arr[0])))
 #define memzero(obj,len) memset(obj,0,len)
 
+// Maximum length of a name in wire format (RFC1035)
+#define HRPZ_MAX_WIRE_NAMELEN 255
+
 // Global lock as the BLAKE3 library is not re-entrant
 pthread_mutex_t hrpz_blake3_mutex;
 
@@ -32,6 +35,14 @@ const char *hrpzerrs[] = {
 	"Wildcard (*) not at start of left hand side",
 	"Domain too long to hash",
 	"Empty Sub Label (eg. dom..example.com)",
+	"Invalid Label Size Policy",
+};
+
+// The default label size policy: <4 => 4 bytes, <8 => 8 bytes, otherwise 16 bytes
+static const hrpz_labelsize_t hrpz_labelsize_tiered = {
+	{ { 4, 4 }, { 8, 8 } },
+	2,
+	16
 };
 
 // hrpz_errstr returns an error string for a given HashedRPZ error
@@ -56,6 +67,9 @@ hrpz_t *hrpz_new(const char *key) {
 		return NULL;
 	}
 
+	// Default label size policy
+	h->labelsize = hrpz_labelsize_tiered;
+
 	// Initialize the hasher and derive the key
 	blake3_hasher_init_derive_key(&h->hasher, key);
 	// Note: we actually re-init before doing anything
@@ -63,6 +77,176 @@ hrpz_t *hrpz_new(const char *key) {
 	return h;
 }
 
+// hrpz_kdf_part adds a length-prefixed (32 bit big-endian) part to the key combination
+static void hrpz_kdf_part(blake3_hasher *kdf, const char *part, size_t len) {
+	uint8_t l[4];
+
+	l[0] = (uint8_t)(len >> 24);
+	l[1] = (uint8_t)(len >> 16);
+	l[2] = (uint8_t)(len >> 8);
+	l[3] = (uint8_t)len;
+
+	blake3_hasher_update(kdf, l, sizeof(l));
+	blake3_hasher_update(kdf, part, len);
+}
+
+/*
+ * hrpz_new_fromkeys creates a new HashedRPZ with the key combined from the in-band key,
+ * the out-of-band key and the origin of the zone, see CombineKeys() in the Golang edition:
+ *
+ *   material = lp(origin) || lp(inband) || lp(outofband)
+ *   key      = lowercase-hex(BLAKE3-DeriveKey(HRPZ_KEY_COMBINATION_CONTEXT, material)[:32])
+ *
+ * The ASCII letters of the origin are lowercased (other bytes are used as-is) and a trailing dot is removed.
+ */
+hrpz_t *hrpz_new_fromkeys(const char *inband, const char *outofband, const char *origindomain) {
+	blake3_hasher	kdf;
+	uint8_t		key[32];
+	char		origin[256],
+			hexkey[sizeof(key)*2 + 1];
+	size_t		olen, i;
+	const char	hexchars[] = "0123456789abcdef";
+
+	if (inband == NULL || outofband == NULL || origindomain == NULL) {
+		return NULL;
+	}
+
+	// Canonical origin: without trailing dot and lowercased
+	olen = strlen(origindomain);
+	if (olen > 0 && origindomain[olen-1] == '.') {
+		olen--;
+	}
+
+	if (olen >= sizeof(origin)) {
+		return NULL;
+	}
+
+	for (i = 0; i < olen; i++) {
+		origin[i] = origindomain[i] >= 'A' && origindomain[i] <= 'Z' ? (char)(origindomain[i] - 'A' + 'a') : origindomain[i];
+	}
+
+	// Due to BLAKE3 library not being re-entrant, lock globally
+	pthread_mutex_lock(&hrpz_blake3_mutex);
+
+	blake3_hasher_init_derive_key(&kdf, HRPZ_KEY_COMBINATION_CONTEXT);
+	hrpz_kdf_part(&kdf, origin, olen);
+	hrpz_kdf_part(&kdf, inband, strlen(inband));
+	hrpz_kdf_part(&kdf, outofband, strlen(outofband));
+	blake3_hasher_finalize(&kdf, key, sizeof(key));
+
+	pthread_mutex_unlock(&hrpz_blake3_mutex);
+
+	for (i = 0; i < sizeof(key); i++) {
+		hexkey[i*2] = hexchars[key[i] >> 4];
+		hexkey[i*2+1] = hexchars[key[i] & 0x0f];
+	}
+	hexkey[sizeof(hexkey)-1] = '\0';
+
+	return hrpz_new(hexkey);
+}
+
+// hrpz_set_labelsize selects the label size policy, the policy is copied
+hrpz_err_t hrpz_set_labelsize(hrpz_t *h, const hrpz_labelsize_t *policy) {
+	size_t i, below = 0;
+
+	if (h == NULL || policy == NULL) {
+		return HRPZ_INVALID_INPUTS;
+	}
+
+	// All sizes between 1 and the maximum and the tiers in increasing order
+	if (policy->size < 1 || policy->size > HRPZ_MAX_LABELSIZE || policy->numtiers > HRPZ_MAX_SIZETIERS) {
+		return HRPZ_ERR_INVALID_LABELSIZE_POLICY;
+	}
+
+	for (i = 0; i < policy->numtiers; i++) {
+		if (policy->tiers[i].below <= below || policy->tiers[i].size < 1 || policy->tiers[i].size > HRPZ_MAX_LABELSIZE) {
+			return HRPZ_ERR_INVALID_LABELSIZE_POLICY;
+		}
+
+		below = policy->tiers[i].below;
+	}
+
+	h->labelsize = *policy;
+
+	return HRPZ_ERR_NONE;
+}
+
+// hrpz_set_labelsize_fixed selects a label size policy using the same digest size for every label
+hrpz_err_t hrpz_set_labelsize_fixed(hrpz_t *h, size_t size) {
+	hrpz_labelsize_t policy;
+
+	memzero(&policy, sizeof(policy));
+	policy.size = size;
+
+	return hrpz_set_labelsize(h, &policy);
+}
+
+/*
+ * hrpz_set_casefold enables (or disables) case folding, see Params.CaseFold in the Golang edition:
+ * the ASCII letters of the left hand side are lowercased (RFC4343) before hashing.
+ *
+ * Enabled by default in scheme version 2, disabled in version 1 (the default of hrpz_new()).
+ */
+hrpz_err_t hrpz_set_casefold(hrpz_t *h, hrpz_bool_t casefold) {
+	if (h == NULL) {
+		return HRPZ_INVALID_INPUTS;
+	}
+
+	h->casefold = casefold ? HRPZ_TRUE : HRPZ_FALSE;
+
+	return HRPZ_ERR_NONE;
+}
+
+// hrpz_update_folded hashes the data with the ASCII letters lowercased, in chunks to avoid a copy of the whole input
+static void hrpz_update_folded(blake3_hasher *hasher, const char *data, size_t len) {
+	char	chunk[64];
+	size_t	n, i;
+
+	while (len > 0) {
+		n = len < sizeof(chunk) ? len : sizeof(chunk);
+
+		for (i = 0; i < n; i++) {
+			chunk[i] = data[i] >= 'A' && data[i] <= 'Z' ? (char)(data[i] - 'A' + 'a') : data[i];
+		}
+
+		blake3_hasher_update(hasher, chunk, n);
+
+		data += n;
+		len -= n;
+	}
+}
+
+// hrpz_digestsize returns the digest size for a label of the given length
+static size_t hrpz_digestsize(const hrpz_labelsize_t *policy, size_t labellen) {
+	size_t i;
+
+	for (i = 0; i < policy->numtiers; i++) {
+		if (labellen < policy->tiers[i].below) {
+			return policy->tiers[i].size;
+		}
+	}
+
+	return policy->size;
+}
+
+// hrpz_wirelen returns the length in wire format (including the root label) of a name, a trailing dot is optional
+static size_t hrpz_wirelen(const char *name) {
+	size_t len = strlen(name);
+
+	if (len > 0 && name[len-1] == '.') {
+		len--;
+	}
+
+	// The dots become the length octets of the labels after them, plus the first label and the root label
+	return len + 2;
+}
+
+// hrpz_keepfit keeps only the rightmost fitcur characters of final, the labels that fit with a wildcard prefixed
+static void hrpz_keepfit(char *final, size_t finalcur, size_t fitcur) {
+	memmove(final, &final[finalcur-fitcur], fitcur);
+	memzero(&final[fitcur], finalcur-fitcur);
+}
+
 // hrpz_cleanup cleans up and frees the HashedRPZ structure
 void hrpz_cleanup(hrpz_t *h) {
 	if (h == NULL) {
@@ -116,11 +300,12 @@ hrpz_err_t hrpz_hash(hrpz_t *h, const char *lefthandside, const char *origindoma
 			maxdomainlen,
 			m,
 			finalcur = 0,
+			fitcur = 0,
 			blen,
 			i;
 	char		c;
-	uint8_t		hsh[16],
-			b32[BASE32_LEN(16)];
+	uint8_t		hsh[HRPZ_MAX_LABELSIZE],
+			b32[BASE32_LEN(HRPZ_MAX_LABELSIZE)+1]; // +1 for the terminating NUL
 
 	// We need a place to put things back into and at least a TLD
 	if (final == NULL || finallen < 5) {
@@ -136,15 +321,17 @@ hrpz_err_t hrpz_hash(hrpz_t *h, const char *lefthandside, const char *origindoma
 	}
 
 	/*
-	 * The maximum domain length:
-	 * 255 - max ownername length as per RFC1035
-	 *  16 - maximum hash length for a label
-	 *   1 - the dot separating hash and origindomain
-	 *   l - the length of the origindomain
-	 *
-	 * Noting that the 'spare' 16 bytes when triggered accomodates a '*.' wildcard easily.
+	 * The maximum length of the result in presentation format:
+	 * 255 - max ownername length in wire format as per RFC1035
+	 *   1 - in wire format every label has a length octet, the dots take the
+	 *       place of all but the first one
+	 *   o - the length of the origindomain in wire format (including the root label)
 	 */
-	maxdomainlen = 255 - 16 - 1 - strlen(origindomain);
+	if (hrpz_wirelen(origindomain) >= HRPZ_MAX_WIRE_NAMELEN - 1) {
+		return HRPZ_ERR_TOO_LONG;
+	}
+
+	maxdomainlen = HRPZ_MAX_WIRE_NAMELEN - 1 - hrpz_wirelen(origindomain);
 
 	/*
 	 * Reject encoding an empty label (root effectively) to empty.
@@ -213,6 +400,12 @@ hrpz_err_t hrpz_hash(hrpz_t *h, const char *lefthandside, const char *origindoma
 				return HRPZ_ERR_TOO_LONG;
 			}
 
+			// The wildcard has to fit as well
+			if ((finalcur + 2) > maxdomainlen) {
+				hrpz_keepfit(final, finalcur, fitcur);
+				return HRPZ_ERR_TOO_LONG;
+			}
+
 			// No need to hash this further
 			memmove(&final[2], final, finalcur);
 			final[0] = '*';
@@ -243,17 +436,14 @@ hrpz_err_t hrpz_hash(hrpz_t *h, const char *lefthandside, const char *origindoma
 		//
 		// The output string length (digest length) does not fully disclose
 		// left hand side length, though gives a decent hint.
+		// (see hrpz_set_labelsize(), the default is tiered: 4, 8 or 16 bytes)
 		m = label - lhs;
 		if (lhs >= label) {
 			return HRPZ_ERR_EMPTY_SUBLABEL;
-		} else if (m < 4) {
-			m = 4;
-		} else if (m < 8) {
-			m = 8;
-		} else {
-			m = 16;
 		}
 
+		m = hrpz_digestsize(&h->labelsize, m);
+
 		// Due to BLAKE3 library not being re-entrant, lock globally
 		pthread_mutex_lock(&hrpz_blake3_mutex);
 
@@ -261,8 +451,12 @@ hrpz_err_t hrpz_hash(hrpz_t *h, const char *lefthandside, const char *origindoma
 		// C has not, thus re-init completely, wee bit slower to the key derivation
 		blake3_hasher_init_derive_key(&h->hasher, h->key);
 
-		// Hash the current part of the lefthandside
-		blake3_hasher_update(&h->hasher, &lefthandside[lhs], lhslen - lhs);
+		// Hash the current part of the lefthandside, lowercased when case folding
+		if (h->casefold) {
+			hrpz_update_folded(&h->hasher, &lefthandside[lhs], lhslen - lhs);
+		} else {
+			blake3_hasher_update(&h->hasher, &lefthandside[lhs], lhslen - lhs);
+		}
 
 		// Get the digest and store it in the hashed buffer
 		blake3_hasher_finalize(&h->hasher, hsh, m);
@@ -297,13 +491,19 @@ hrpz_err_t hrpz_hash(hrpz_t *h, const char *lefthandside, const char *origindoma
 		 * there is a hash for a video-id or tracking purposes encoded in them
 		 * thus we limit generating very long RPZ elements as they would not
 		 * fit in the destination domain.
-		 * and replace it with a wildcard; which might mean more gets blocked
+		 * and return what fits with a wildcard; which might mean more gets blocked
 		 * than needed.
 		 */
-		if (finalcur >= maxdomainlen) {
+		if (finalcur > maxdomainlen) {
+			hrpz_keepfit(final, finalcur, fitcur);
 			return HRPZ_ERR_TOO_LONG;
 		}
 
+		// Would this still fit when prefixed with a wildcard?
+		if ((finalcur + 2) <= maxdomainlen) {
+			fitcur = finalcur;
+		}
+
 		if (callback != NULL) {
 			callback(&lefthandside[lhs], final);
 		}
@@ -319,8 +519,8 @@ hrpz_err_t hrpz_hash(hrpz_t *h, const char *lefthandside, const char *origindoma
 }
 
 /*
- * hrpz_hashwildcard calls hrpz_hash() but when the maxdomainlength is exceeded, it encodes
- * the remaining labels as a wildcard inside the domain that fitted.
+ * hrpz_hashwildcard calls hrpz_hash() but when the ownername would exceed 255 octets, it encodes
+ * the remaining labels as a wildcard inside the domain that fitted, thus the result always fits.
  *
  * Thus for example an input of ```host.v.e.r.y.l.o.n.g.example.com``` would
  * encode as ```*.n.g.example.com```
@@ -21,11 +21,38 @@
  */
 #include "BLAKE3/c/blake3.h"
 
+/* Largest digest size (in bytes) that a label size policy can select */
+#define HRPZ_MAX_LABELSIZE 32
+
+/* Maximum number of tiers in a label size policy */
+#define HRPZ_MAX_SIZETIERS 8
+
+/*
+ * A tier of a label size policy: labels shorter than 'below' get a 'size' bytes digest
+ */
+typedef struct hrpz_sizetier {
+	size_t		below;
+	size_t		size;
+} hrpz_sizetier_t;
+
+/*
+ * Label size policy, see LabelSizePolicy in the Golang edition
+ *
+ * The tiers are checked in order, when no tier applies 'size' is used.
+ */
+typedef struct hrpz_labelsize {
+	hrpz_sizetier_t	tiers[HRPZ_MAX_SIZETIERS];
+	size_t		numtiers;
+	size_t		size;
+} hrpz_labelsize_t;
+
 /*
  * Storage for HashedRPZ abstraction
  */
 typedef struct hrpz {
 	char		*key;
+	hrpz_labelsize_t labelsize;
+	int		casefold;
 	blake3_hasher	hasher;
 } hrpz_t;
 
@@ -45,15 +72,27 @@ enum HRPZ_ERR {
 	HRPZ_ERR_EMPTY_LABEL,
 	HRPZ_ERR_WILDCARD_NOT_AT_START,
 	HRPZ_ERR_TOO_LONG,
-	HRPZ_ERR_EMPTY_SUBLABEL
+	HRPZ_ERR_EMPTY_SUBLABEL,
+	HRPZ_ERR_INVALID_LABELSIZE_POLICY
 };
 
 const char *hrpz_errstr(hrpz_err_t err);
 
 hrpz_t *hrpz_new(const char *key);
 
+/* BLAKE3 DeriveKey context for hrpz_new_fromkeys(), same as KeyCombinationContext in the Golang edition */
+#define HRPZ_KEY_COMBINATION_CONTEXT "github.com/massar/hashedrpz 2026-10-16 in-band out-of-band key combination v1"
+
+hrpz_t *hrpz_new_fromkeys(const char *inband, const char *outofband, const char *origindomain);
+
 void hrpz_reset(hrpz_t *h);
 
+hrpz_err_t hrpz_set_labelsize(hrpz_t *h, const hrpz_labelsize_t *policy);
+
+hrpz_err_t hrpz_set_labelsize_fixed(hrpz_t *h, size_t size);
+
+hrpz_err_t hrpz_set_casefold(hrpz_t *h, hrpz_bool_t casefold);
+
 void hrpz_cleanup(hrpz_t *h);
 
 hrpz_err_t hrpz_hash(hrpz_t *h, const char *lefthandside, const char *origindomain, hrpz_callback_t callback, char *final, size_t finallen);
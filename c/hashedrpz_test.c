@@ -48,7 +48,38 @@ test_t tests[] = {
 	{"m*.example.net", "", HRPZ_ERR_WILDCARD_NOT_AT_START, HRPZ_ERR_WILDCARD_NOT_AT_START, 2},
 	{"empty..sublabel.example.net", "", HRPZ_ERR_EMPTY_SUBLABEL, HRPZ_ERR_EMPTY_SUBLABEL, 3},
 	{"empty.sublabel..", "", HRPZ_ERR_EMPTY_SUBLABEL, HRPZ_ERR_EMPTY_SUBLABEL, 0},
-	{"a.b.c.d.e.f.g.h.i.j.k.l.m.n.o.p.q.r.s.t.u.v.w.x.y.z.0123456789abcdefghijklmnopqrstuv.example.net", "*.j5ni418.hv8ls60.ptilhs8.11v1t7g.6esbkao.kce9ido.ib563vg.4dlie60.ckn4lb0.kibrgt8.j2lie10.k481ego.2e8lg50.n1lr5g8.qcs689g.klfks3o.m86tq2g.jsheic0.v3009s8.sou3820.vbkvv38.679i40o.bqfs4mpqnia3vm63efg45eg7t0.kj8qsm2gn1o42.1qpnbgg", HRPZ_ERR_TOO_LONG, HRPZ_ERR_NONE, 24},
+	{"a.b.c.d.e.f.g.h.i.j.k.l.m.n.o.p.q.r.s.t.u.v.w.x.y.z.0123456789abcdefghijklmnopqrstuv.example.net", "*.fhe4qeo.j5ni418.hv8ls60.ptilhs8.11v1t7g.6esbkao.kce9ido.ib563vg.4dlie60.ckn4lb0.kibrgt8.j2lie10.k481ego.2e8lg50.n1lr5g8.qcs689g.klfks3o.m86tq2g.jsheic0.v3009s8.sou3820.vbkvv38.679i40o.bqfs4mpqnia3vm63efg45eg7t0.kj8qsm2gn1o42.1qpnbgg", HRPZ_ERR_TOO_LONG, HRPZ_ERR_NONE, 26},
+};
+
+// Label size policies for the label size test vectors (same as labelsize_test.go)
+const hrpz_labelsize_t fixed4 = { { { 0, 0 } }, 0, 4 };
+const hrpz_labelsize_t fixed16 = { { { 0, 0 } }, 0, 16 };
+const hrpz_labelsize_t fixed32 = { { { 0, 0 } }, 0, 32 };
+const hrpz_labelsize_t custom = { { { 3, 6 }, { 10, 10 } }, 2, 20 };
+
+typedef struct {
+	const char		*name;
+	const hrpz_labelsize_t	*policy;
+	const char		*input;
+	const char		*output;
+} lstest_t;
+
+// lstests are the test vectors per label size policy (the tiered default is covered by tests)
+lstest_t lstests[] = {
+	{"fixed4", &fixed4, "com", "8r4m02g"},
+	{"fixed4", &fixed4, "www.example.com", "qtr7pq8.slhf50g.8r4m02g"},
+	{"fixed4", &fixed4, "longerlabel.example.net", "n10m898.kj8qsm0.1qpnbgg"},
+	{"fixed4", &fixed4, "*.example.net", "*.kj8qsm0.1qpnbgg"},
+	{"fixed16", &fixed16, "com", "8r4m02nd4cvnat530nvkkt641s"},
+	{"fixed16", &fixed16, "www.example.com", "qtr7pqf522v0k5rmg0e2l71flk.slhf50h8dgst0t6a2juct0plr0.8r4m02nd4cvnat530nvkkt641s"},
+	{"fixed16", &fixed16, "longerlabel.example.net", "n10m898sngepm1u6t1h4hjkqhc.kj8qsm2gn1o43mndcdnf6n0ba8.1qpnbgntspv360nm1obolb5cag"},
+	{"fixed16", &fixed16, "*.example.net", "*.kj8qsm2gn1o43mndcdnf6n0ba8.1qpnbgntspv360nm1obolb5cag"},
+	{"fixed32", &fixed32, "com", "8r4m02nd4cvnat530nvkkt641t65cb0nhc4ukk82jaj7tbagbqv0"},
+	{"fixed32", &fixed32, "example.com", "slhf50h8dgst0t6a2juct0plr37uo26241jesjj6onpngamggs20.8r4m02nd4cvnat530nvkkt641t65cb0nhc4ukk82jaj7tbagbqv0"},
+	{"custom", &custom, "com", "8r4m02nd4cvnat53"},
+	{"custom", &custom, "www.example.com", "qtr7pqf522v0k5rm.slhf50h8dgst0t6a.8r4m02nd4cvnat53"},
+	{"custom", &custom, "longerlabel.example.net", "n10m898sngepm1u6t1h4hjkqhclqtqkv.kj8qsm2gn1o43mnd.1qpnbgntspv360nm"},
+	{"custom", &custom, "*.example.net", "*.kj8qsm2gn1o43mnd.1qpnbgntspv360nm"},
 };
 
 #define ATTR_FORMAT(type, x, y) __attribute__ ((format(type, x, y)))
@@ -177,6 +208,187 @@ int testhashwildcard(test_t *t) {
 	return res;
 }
 
+int testlabelsize(lstest_t *t);
+int testlabelsize(lstest_t *t) {
+	hrpz_err_t	err;
+	char		final[1024];
+	int		res = 0;
+	const char	tname[] = "LabelSize";
+
+	hrpz_t *h = hrpz_new(testkey);
+
+	while (res == 0) {
+		if (h == NULL) {
+			fprintf(stderr, "FAIL: Could not initialize HashedRPZ\n");
+			res = 1;
+			break;
+		}
+
+		err = hrpz_set_labelsize(h, t->policy);
+		if (err != HRPZ_ERR_NONE) {
+			fprintf(stderr, "FAIL: %s(%s) Could not set policy: \"%s\"\n", tname, t->name, hrpz_errstr(err));
+			res = 1;
+			break;
+		}
+
+		err = hrpz_hash(h, t->input, origindomain, HRPZ_NOCALLBACK, final, sizeof(final));
+
+		v(2, "%-20s (%s): \"%s\" => \"%s\"\n", tname, t->name, t->input, final);
+
+		if (err != HRPZ_ERR_NONE) {
+			fprintf(stderr, "FAIL: %s(%s/%s) Unexpected error %d (\"%s\")\n", tname, t->name, t->input, err, hrpz_errstr(err));
+			res = 1;
+			break;
+		}
+
+		if (strcmp(t->output, final) != 0) {
+			fprintf(stderr, "FAIL: %s(%s/%s) Expected \"%s\", got \"%s\"\n", tname, t->name, t->input, t->output, final);
+			res = 1;
+			break;
+		}
+
+		break;
+	}
+
+	hrpz_cleanup(h);
+
+	return res;
+}
+
+int testfromkeys(void);
+int testfromkeys(void) {
+	hrpz_err_t	err;
+	char		final[1024];
+	int		res = 0;
+	const char	tname[] = "FromKeys";
+	const char	*input = "www.example.com";
+	const char	*output = "aek2i7g.gubiuije6oavs.j7ovnv0";
+
+	// Same vector as keys_test.go, the origin is canonicalized
+	hrpz_t *h = hrpz_new_fromkeys("0KjULoiv d2VFuNPc", "RVabpOq3 eN6bmK0Z 2gwjCgDf fU2HVN5A", "RPZ.Example.NET.");
+
+	while (res == 0) {
+		if (h == NULL) {
+			fprintf(stderr, "FAIL: Could not initialize HashedRPZ\n");
+			res = 1;
+			break;
+		}
+
+		err = hrpz_hash(h, input, origindomain, HRPZ_NOCALLBACK, final, sizeof(final));
+
+		v(2, "%-20s: \"%s\" => \"%s\"\n", tname, input, final);
+
+		if (err != HRPZ_ERR_NONE) {
+			fprintf(stderr, "FAIL: %s(%s) Unexpected error %d (\"%s\")\n", tname, input, err, hrpz_errstr(err));
+			res = 1;
+			break;
+		}
+
+		if (strcmp(output, final) != 0) {
+			fprintf(stderr, "FAIL: %s(%s) Expected \"%s\", got \"%s\"\n", tname, input, output, final);
+			res = 1;
+			break;
+		}
+
+		break;
+	}
+
+	hrpz_cleanup(h);
+
+	return res;
+}
+
+int testfromkeysnonascii(void);
+int testfromkeysnonascii(void) {
+	hrpz_err_t	err;
+	char		final[1024];
+	int		res = 0;
+	const char	tname[] = "FromKeysNonASCII";
+	const char	*input = "www.example.com";
+	const char	*output = "b08dkc8.5t70em72r6alk.d4pv9lg";
+
+	// Same vector as keys_test.go, only the ASCII letters of the origin (RPZ.EX\u00c4MPLE.NET.) are lowercased
+	hrpz_t *h = hrpz_new_fromkeys("0KjULoiv d2VFuNPc", "RVabpOq3 eN6bmK0Z 2gwjCgDf fU2HVN5A", "RPZ.EX\xc3\x84MPLE.NET.");
+
+	while (res == 0) {
+		if (h == NULL) {
+			fprintf(stderr, "FAIL: Could not initialize HashedRPZ\n");
+			res = 1;
+			break;
+		}
+
+		err = hrpz_hash(h, input, origindomain, HRPZ_NOCALLBACK, final, sizeof(final));
+
+		v(2, "%-20s: \"%s\" => \"%s\"\n", tname, input, final);
+
+		if (err != HRPZ_ERR_NONE) {
+			fprintf(stderr, "FAIL: %s(%s) Unexpected error %d (\"%s\")\n", tname, input, err, hrpz_errstr(err));
+			res = 1;
+			break;
+		}
+
+		if (strcmp(output, final) != 0) {
+			fprintf(stderr, "FAIL: %s(%s) Expected \"%s\", got \"%s\"\n", tname, input, output, final);
+			res = 1;
+			break;
+		}
+
+		break;
+	}
+
+	hrpz_cleanup(h);
+
+	return res;
+}
+
+int testcasefold(void);
+int testcasefold(void) {
+	hrpz_err_t	err;
+	char		final[1024];
+	int		res = 0;
+	unsigned int	i;
+	const char	tname[] = "CaseFold";
+	const char	*inputs[] = { "www.example.com", "WWW.EXAMPLE.COM", "wWw.eXaMpLe.CoM." };
+	const char	*output = "qtr7pq8.slhf50h8dgst0.8r4m02g";
+
+	// Same vectors as TestHashCaseFold in hashedrpz_test.go
+	hrpz_t *h = hrpz_new(testkey);
+
+	while (res == 0) {
+		if (h == NULL) {
+			fprintf(stderr, "FAIL: Could not initialize HashedRPZ\n");
+			res = 1;
+			break;
+		}
+
+		hrpz_set_casefold(h, HRPZ_TRUE);
+
+		for (i = 0; i < lengthof(inputs); i++) {
+			err = hrpz_hash(h, inputs[i], origindomain, HRPZ_NOCALLBACK, final, sizeof(final));
+
+			v(2, "%-20s: \"%s\" => \"%s\"\n", tname, inputs[i], final);
+
+			if (err != HRPZ_ERR_NONE) {
+				fprintf(stderr, "FAIL: %s(%s) Unexpected error %d (\"%s\")\n", tname, inputs[i], err, hrpz_errstr(err));
+				res = 1;
+				break;
+			}
+
+			if (strcmp(output, final) != 0) {
+				fprintf(stderr, "FAIL: %s(%s) Expected \"%s\", got \"%s\"\n", tname, inputs[i], output, final);
+				res = 1;
+				break;
+			}
+		}
+
+		break;
+	}
+
+	hrpz_cleanup(h);
+
+	return res;
+}
+
 int main(int argc, char* argv[]) {
 	unsigned int	i;
 	int		a, n, totfails = 0;
@@ -199,6 +411,27 @@ int main(int argc, char* argv[]) {
 		totfails += n;
 	}
 
+	for (i = 0; i < lengthof(lstests); i++) {
+		lstest_t *t = &lstests[i];
+
+		n = testlabelsize(t);
+
+		v(1, "    --- %s: LabelSize/%s/%s\n", (n == 0 ? "PASS" : "FAIL"), t->name, t->input);
+		totfails += n;
+	}
+
+	n = testfromkeys();
+	v(1, "    --- %s: FromKeys\n", (n == 0 ? "PASS" : "FAIL"));
+	totfails += n;
+
+	n = testfromkeysnonascii();
+	v(1, "    --- %s: FromKeysNonASCII\n", (n == 0 ? "PASS" : "FAIL"));
+	totfails += n;
+
+	n = testcasefold();
+	v(1, "    --- %s: CaseFold\n", (n == 0 ? "PASS" : "FAIL"));
+	totfails += n;
+
 	// Always print PASS / FAIL
 	printf("%s\n", totfails == 0 ? "PASS" : "FAIL");
 
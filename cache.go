@@ -0,0 +1,209 @@
+package hashedrpz
+
+// A bounded LRU cache of hashed suffixes, avoiding rehashing the same parents (e.g. ```example.com``` and ```com```) in bulk.
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// SuffixCache is a bounded LRU cache of the hashed labels of suffixes, it is safe for concurrent use.
+//
+// When hashing a feed, ```www.example.com``` and ```mail.example.com``` both hash the
+// suffixes ```example.com``` and ```com```, with a SuffixCache (see WithSuffixCache) these
+// are only hashed once. The entries are keyed by a fingerprint of the key of the HashedRPZ
+// and the digest size, thus a single cache can be shared between multiple HashedRPZs
+// (e.g. the ones of a Keyring) without mixing up their results.
+//
+// Larger caches are split in shards by the hash of the cache key, each with its own lock and LRU,
+// thus concurrent callers rarely wait for each other. The least recently used entry of a shard is evicted.
+//
+// The cache holds plaintext suffixes of the names hashed, treat it with the same care as the feed.
+type SuffixCache struct {
+	// hits, misses and evictions are the statistics, updated atomically (first for their alignment)
+	hits      uint64
+	misses    uint64
+	evictions uint64
+
+	// shards are the parts of the cache, the number of shards is a power of two
+	shards []cacheShard
+}
+
+// cacheShard is a part of the SuffixCache with its own lock and LRU
+type cacheShard struct {
+	mu sync.Mutex
+
+	// size is the maximum number of entries
+	size int
+
+	// entries maps the cache key to the element in lru
+	entries map[string]*list.Element
+
+	// lru is the list of entries, the most recently used at the front
+	lru *list.List
+}
+
+// The number of shards grows with the size, upto maxCacheShards, while keeping at least minShardSize entries per shard
+const (
+	maxCacheShards = 64
+	minShardSize   = 128
+)
+
+// cacheEntry is a single entry of the SuffixCache
+type cacheEntry struct {
+	key  string
+	hash []byte
+}
+
+// CacheStats are the statistics of a SuffixCache
+type CacheStats struct {
+	// Hits is the number of lookups that were found in the cache
+	Hits uint64
+
+	// Misses is the number of lookups that were not found in the cache, thus hashed
+	Misses uint64
+
+	// Evictions is the number of entries removed to make room for new ones
+	Evictions uint64
+
+	// Entries is the current number of entries in the cache
+	Entries int
+}
+
+// HitRate returns the fraction of lookups that were found in the cache (0 when there were no lookups)
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// NewSuffixCache creates a SuffixCache holding at most size entries,
+// it returns nil (thus no caching) when size is 0 or less.
+func NewSuffixCache(size int) *SuffixCache {
+	if size <= 0 {
+		return nil
+	}
+
+	n := 1
+	for n < maxCacheShards && size/(n*2) >= minShardSize {
+		n *= 2
+	}
+
+	c := &SuffixCache{shards: make([]cacheShard, n)}
+
+	for i := range c.shards {
+		// The remainder is spread over the first shards
+		c.shards[i].size = size / n
+		if i < size%n {
+			c.shards[i].size++
+		}
+
+		c.shards[i].entries = make(map[string]*list.Element)
+		c.shards[i].lru = list.New()
+	}
+
+	return c
+}
+
+// WithSuffixCache uses the cache for the hashed labels, see SuffixCache.
+// A nil cache disables caching (the default).
+func WithSuffixCache(c *SuffixCache) Option {
+	return func(o *options) {
+		o.cache = c
+	}
+}
+
+// Stats returns the statistics of the cache
+func (c *SuffixCache) Stats() (s CacheStats) {
+	s.Hits = atomic.LoadUint64(&c.hits)
+	s.Misses = atomic.LoadUint64(&c.misses)
+	s.Evictions = atomic.LoadUint64(&c.evictions)
+
+	for i := range c.shards {
+		sh := &c.shards[i]
+
+		sh.mu.Lock()
+		s.Entries += sh.lru.Len()
+		sh.mu.Unlock()
+	}
+
+	return
+}
+
+// Purge removes all entries from the cache, the statistics are kept
+func (c *SuffixCache) Purge() {
+	for i := range c.shards {
+		sh := &c.shards[i]
+
+		sh.mu.Lock()
+		sh.entries = make(map[string]*list.Element)
+		sh.lru.Init()
+		sh.mu.Unlock()
+	}
+
+	return
+}
+
+// shard returns the shard of the key, selected by its FNV-1a hash
+func (c *SuffixCache) shard(key []byte) *cacheShard {
+	if len(c.shards) == 1 {
+		return &c.shards[0]
+	}
+
+	h := uint32(2166136261)
+	for _, b := range key {
+		h ^= uint32(b)
+		h *= 16777619
+	}
+
+	return &c.shards[h&uint32(len(c.shards)-1)]
+}
+
+// get appends the cached hash for the key to dst, ok is false when it was not cached
+func (c *SuffixCache) get(dst []byte, key []byte) (out []byte, ok bool) {
+	sh := c.shard(key)
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	// The string conversion in a map index does not allocate
+	e, ok := sh.entries[string(key)]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return dst, false
+	}
+
+	atomic.AddUint64(&c.hits, 1)
+	sh.lru.MoveToFront(e)
+
+	return append(dst, e.Value.(*cacheEntry).hash...), true
+}
+
+// put stores the hash for the key, evicting the least recently used entry of the shard when full
+func (c *SuffixCache) put(key []byte, hash []byte) {
+	sh := c.shard(key)
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	// Another caller might have been faster
+	if e, ok := sh.entries[string(key)]; ok {
+		sh.lru.MoveToFront(e)
+		return
+	}
+
+	if sh.lru.Len() >= sh.size {
+		e := sh.lru.Back()
+		sh.lru.Remove(e)
+		delete(sh.entries, e.Value.(*cacheEntry).key)
+		atomic.AddUint64(&c.evictions, 1)
+	}
+
+	entry := &cacheEntry{key: string(key), hash: append([]byte(nil), hash...)}
+	sh.entries[entry.key] = sh.lru.PushFront(entry)
+
+	return
+}
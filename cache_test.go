@@ -0,0 +1,217 @@
+package hashedrpz
+
+// Tests and benchmarks for the SuffixCache
+
+import (
+	"strconv"
+	"testing"
+)
+
+// TestSuffixCache checks that the cached results are the same as the uncached ones
+func TestSuffixCache(t *testing.T) {
+	if NewSuffixCache(0) != nil {
+		t.Fatalf("Expected no cache for size 0")
+	}
+
+	c := NewSuffixCache(1000)
+	h := New(testkey)
+	hc := New(testkey, WithSuffixCache(c))
+
+	// Twice, the second time everything comes from the cache
+	for pass := 0; pass < 2; pass++ {
+		for _, tt := range tests {
+			exp, experr := h.Hash(tt.Input, origindomain, NoCallback)
+
+			o, err := hc.Hash(tt.Input, origindomain, NoCallback)
+			if !sameError(err, experr) || o != exp {
+				t.Fatalf("Expected %q (%v) for %q but got: %q (%v)", exp, experr, tt.Input, o, err)
+			}
+		}
+
+		s := c.Stats()
+		if pass == 0 && (s.Hits == 0 || s.Misses == 0) {
+			t.Errorf("Expected hits and misses, got %+v", s)
+		}
+
+		if pass == 1 && s.Misses != uint64(s.Entries) {
+			t.Errorf("Expected only hits in the second pass, got %+v", s)
+		}
+	}
+
+	if r := c.Stats().HitRate(); r <= 0.5 || r >= 1 {
+		t.Errorf("Unexpected hit rate %f", r)
+	}
+
+	c.Purge()
+
+	if s := c.Stats(); s.Entries != 0 {
+		t.Errorf("Expected no entries after purge, got %+v", s)
+	}
+
+	return
+}
+
+// TestSuffixCacheShared checks that a cache shared between different keys and label sizes keeps them apart
+func TestSuffixCacheShared(t *testing.T) {
+	c := NewSuffixCache(1000)
+
+	configs := []struct {
+		Key  string
+		Opts []Option
+	}{
+		{testkey, nil},
+		{testmaster, nil},
+		{testkey, []Option{WithLabelSizePolicy(LabelSizePolicy{Size: 16})}},
+		{testkey, []Option{WithParams(ParamsV2())}},
+		{testkey, []Option{WithParams(Params{Version: SchemeV1, LabelSize: TieredLabelSize, Hash: HashHMACSHA256})}},
+	}
+
+	// Twice, thus the second round can only be wrong when the entries get mixed up
+	for i := 0; i < 2; i++ {
+		for _, cfg := range configs {
+			plain := New(cfg.Key, cfg.Opts...)
+			exp, _ := plain.Hash("www.Example.com", origindomain, NoCallback)
+
+			h := New(cfg.Key, append(cfg.Opts, WithSuffixCache(c))...)
+
+			o, err := h.Hash("www.Example.com", origindomain, NoCallback)
+			if err != nil || o != exp {
+				t.Errorf("Expected %q for %q but got: %q (%v)", exp, cfg.Key, o, err)
+			}
+		}
+	}
+
+	return
+}
+
+// TestSuffixCacheOrigins checks that with domain separation the origins are kept apart
+func TestSuffixCacheOrigins(t *testing.T) {
+	c := NewSuffixCache(1000)
+	h := New(testkey, WithParams(ParamsV3()), WithSuffixCache(c))
+
+	for i := 0; i < 2; i++ {
+		for _, tt := range migrationTests {
+			if tt.Version != SchemeV3 {
+				continue
+			}
+
+			o, err := h.Hash(tt.Input, tt.Origin, NoCallback)
+			if err != nil || o != tt.Output {
+				t.Errorf("Expected %q for %q in %q but got: %q (%v)", tt.Output, tt.Input, tt.Origin, o, err)
+			}
+		}
+	}
+
+	return
+}
+
+// TestSuffixCacheEviction checks that the cache stays within its size
+func TestSuffixCacheEviction(t *testing.T) {
+	c := NewSuffixCache(2)
+	h := New(testkey, WithSuffixCache(c))
+
+	plain := New(testkey)
+	exp, _ := plain.Hash("a.b.c.example.net", origindomain, NoCallback)
+
+	for i := 0; i < 2; i++ {
+		o, err := h.Hash("a.b.c.example.net", origindomain, NoCallback)
+		if err != nil || o != exp {
+			t.Errorf("Expected %q but got: %q (%v)", exp, o, err)
+		}
+	}
+
+	// Every lookup evicts the entry that is needed next
+	s := c.Stats()
+	if s.Entries != 2 || s.Hits != 0 || s.Misses != 10 || s.Evictions != 8 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+
+	return
+}
+
+// TestSuffixCacheShards checks that larger caches are sharded and stay within their size
+func TestSuffixCacheShards(t *testing.T) {
+	tests := []struct {
+		Size   int
+		Shards int
+	}{
+		{1, 1},
+		{255, 1},
+		{256, 2},
+		{1000, 4},
+		{100000, maxCacheShards},
+	}
+
+	for _, tt := range tests {
+		c := NewSuffixCache(tt.Size)
+
+		total := 0
+		for i := range c.shards {
+			total += c.shards[i].size
+		}
+
+		if len(c.shards) != tt.Shards || total != tt.Size {
+			t.Errorf("Expected %d shards for size %d, got %d shards holding %d", tt.Shards, tt.Size, len(c.shards), total)
+		}
+	}
+
+	c := NewSuffixCache(1000)
+	h := New(testkey, WithSuffixCache(c))
+
+	for i := 0; i < 2000; i++ {
+		h.Hash("host"+strconv.Itoa(i)+".example.net", origindomain, NoCallback)
+	}
+
+	if s := c.Stats(); s.Entries > 1000 || s.Entries+int(s.Evictions) != int(s.Misses) {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+
+	return
+}
+
+// BenchmarkAppendHashParallelCached hashes names in a few domains from all goroutines with a shared SuffixCache
+//
+// Run with e.g. '-cpu 1,2,4,8' to see that the throughput scales with GOMAXPROCS, as the cache is sharded.
+func BenchmarkAppendHashParallelCached(b *testing.B) {
+	c := NewSuffixCache(100000)
+	h := New(bench10Mkey, WithSuffixCache(c))
+	origin := []byte(origindomain)
+
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		dst := make([]byte, 0, 512)
+		name := make([]byte, 0, 64)
+		i := 0
+
+		for pb.Next() {
+			name = append(strconv.AppendInt(append(name[:0], "host"...), int64(i%5000), 10), ".example"...)
+			name = append(strconv.AppendInt(name, int64(i%16), 10), ".com"...)
+			i++
+
+			var err error
+
+			dst, err = h.AppendHash(dst[:0], name, origin)
+			if err != nil {
+				b.Errorf("Failed: %s", err)
+				return
+			}
+		}
+	})
+
+	b.ReportMetric(c.Stats().HitRate(), "hitrate")
+
+	return
+}
+
+// BenchmarkHash10MCached is BenchmarkHash10M with a SuffixCache, reporting the hit rate
+func BenchmarkHash10MCached(b *testing.B) {
+	c := NewSuffixCache(100000)
+	h := New(bench10Mkey, WithSuffixCache(c))
+
+	benchmarkHash10M(b, h)
+
+	b.ReportMetric(c.Stats().HitRate(), "hitrate")
+
+	return
+}
@@ -4,8 +4,10 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"net/netip"
 	"os"
 
 	"github.com/massar/hashedrpz"
@@ -28,23 +30,43 @@ func init() {
 func main() {
 	var (
 		key           string
+		inbandkey     string
+		outofbandkey  string
 		origindomain  string
+		params        string
+		strict        string
+		trigger       string
+		cachesize     int
 		makewildcard  bool
+		collapse      bool
 		ignoretoolong bool
 		echoownername bool
 		addwildcards  bool
 	)
 
 	flag.StringVar(&key, "key", "", "The HashedRPZ Key")
+	flag.StringVar(&inbandkey, "inbandkey", "", "The in-band key (as in the _rpzhashkey TXT record), combined with '-outofbandkey' and the origindomain instead of '-key'")
+	flag.StringVar(&outofbandkey, "outofbandkey", "", "The out-of-band key, combined with '-inbandkey' and the origindomain instead of '-key'")
 	flag.StringVar(&origindomain, "origindomain", "", "The origindomain where this label will be included in (e.g. ```rpz.example.com```)")
+	flag.StringVar(&params, "params", hashedrpz.DefaultParams().String(), "The HashedRPZ scheme parameters")
+	flag.StringVar(&strict, "strict", "", "Strictly validate the names using the character rule (any, ldh or ldh-underscore), invalid names are reported on stderr and skipped")
+	flag.StringVar(&trigger, "trigger", "qname", "The trigger of the inputs: qname, rpz-nsdname (names of nameservers), rpz-ip or rpz-nsip (addresses or prefixes, e.g. 192.0.2.0/24), the results are placed under that trigger label")
+	flag.IntVar(&cachesize, "cache", 0, "Cache the hashes of upto this many suffixes (e.g. example.com), speeds up hashing many names in the same domains, statistics are reported on stderr")
 	flag.BoolVar(&makewildcard, "makewildcard", false, "For domains exceeding the maxdomainlength either: false: cause an error (default), true: encode the too long items as a wildcard (will overblock adjacent labels in the same subdomain)")
+	flag.BoolVar(&collapse, "collapse", false, "For domains exceeding the maxdomainlength hash the labels that do not fit as a single collapsed label (exact, unlike -makewildcard)")
 	flag.BoolVar(&ignoretoolong, "ignoretoolong", false, "Ignores domains that exceed the maxdomainlength")
 	flag.BoolVar(&echoownername, "echoownername", false, "Echos the ownername before the resulting hash")
 	flag.BoolVar(&addwildcards, "addwildcards", false, "Inputs are domains, thus also output a wildcard hostname, to be able to block the labels inside the domain")
 	flag.Parse()
 
-	if key == "" {
-		fmt.Fprintf(os.Stderr, "Missing HashedRPZ Key, please provide using '-key <keystring>'\n")
+	if key == "" && outofbandkey == "" {
+		fmt.Fprintf(os.Stderr, "Missing HashedRPZ Key, please provide using '-key <keystring>' or '-inbandkey <keystring> -outofbandkey <keystring>'\n")
+		os.Exit(1)
+		return
+	}
+
+	if key != "" && (inbandkey != "" || outofbandkey != "") {
+		fmt.Fprintf(os.Stderr, "Provide either '-key' or '-inbandkey' and '-outofbandkey', not both\n")
 		os.Exit(1)
 		return
 	}
@@ -55,8 +77,64 @@ func main() {
 		return
 	}
 
+	switch trigger {
+	case "qname", hashedrpz.TriggerNSDName:
+		if trigger == hashedrpz.TriggerNSDName && collapse {
+			fmt.Fprintf(os.Stderr, "'-collapse' is not supported for the %s trigger\n", trigger)
+			os.Exit(1)
+			return
+		}
+
+	case hashedrpz.TriggerIP, hashedrpz.TriggerNSIP:
+		if makewildcard || collapse || addwildcards {
+			fmt.Fprintf(os.Stderr, "'-makewildcard', '-collapse' and '-addwildcards' are not supported for the %s trigger\n", trigger)
+			os.Exit(1)
+			return
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid trigger %q, please provide one of qname, %s, %s or %s\n", trigger, hashedrpz.TriggerNSDName, hashedrpz.TriggerIP, hashedrpz.TriggerNSIP)
+		os.Exit(1)
+		return
+	}
+
+	p, err := hashedrpz.ParseParams(params)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid parameters %q: %s\n", params, err)
+		os.Exit(1)
+		return
+	}
+
+	// Combine the in-band and out-of-band keys when provided
+	if key == "" {
+		key = hashedrpz.CombineKeys(inbandkey, outofbandkey, origindomain)
+	}
+
+	opts := []hashedrpz.Option{hashedrpz.WithParams(p)}
+
+	if strict != "" {
+		chars, err := hashedrpz.ParseCharRule(strict)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid strict character rule %q: %s\n", strict, err)
+			os.Exit(1)
+			return
+		}
+
+		opts = append(opts, hashedrpz.WithStrict(chars))
+	}
+
+	cache := hashedrpz.NewSuffixCache(cachesize)
+	if cache != nil {
+		opts = append(opts, hashedrpz.WithSuffixCache(cache))
+	}
+
 	// Create a new HashedRPZ
-	h := hashedrpz.New(key)
+	h, err := hashedrpz.NewWithOptions(key, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid options: %s\n", err)
+		os.Exit(1)
+		return
+	}
 
 	lineno := 0
 
@@ -73,23 +151,50 @@ func main() {
 
 		iswildcard := false
 
-		if makewildcard {
+		if trigger == hashedrpz.TriggerIP || trigger == hashedrpz.TriggerNSIP {
+			var prefix netip.Prefix
+
+			prefix, err = parsePrefix(line)
+			if err == nil && trigger == hashedrpz.TriggerIP {
+				r, err = h.HashIP(prefix, origindomain)
+			} else if err == nil {
+				r, err = h.HashNSIP(prefix, origindomain)
+			}
+		} else if trigger == hashedrpz.TriggerNSDName {
+			r, err = h.HashNSDName(line, origindomain, hashedrpz.NoCallback)
+			if errors.Is(err, hashedrpz.ErrTooLong) && makewildcard {
+				r, iswildcard, err = "*."+r, true, nil
+			} else if errors.Is(err, hashedrpz.ErrTooLong) && ignoretoolong {
+				// The partial result is not the trigger of the name
+				fmt.Fprintf(os.Stderr, "Skipping line %d (%q): %s\n", lineno, line, err)
+				continue
+			}
+		} else if makewildcard {
 			r, iswildcard, err = h.HashWildcard(line, origindomain, hashedrpz.NoCallback)
+		} else if collapse {
+			r, _, err = h.HashCollapsed(line, origindomain, hashedrpz.NoCallback)
 		} else {
 			r, err = h.Hash(line, origindomain, hashedrpz.NoCallback)
-			if ignoretoolong && err == hashedrpz.ErrTooLong {
-				err = nil
+			if ignoretoolong && errors.Is(err, hashedrpz.ErrTooLong) {
+				// The partial result is not the trigger of the name
+				fmt.Fprintf(os.Stderr, "Skipping line %d (%q): %s\n", lineno, line, err)
+				continue
 			}
 		}
 
+		// Names that can not exist in the DNS (only with -strict)
+		var verr *hashedrpz.ValidationError
+		if errors.As(err, &verr) {
+			fmt.Fprintf(os.Stderr, "Skipping line %d (%q): %s\n", lineno, line, err)
+			continue
+		}
+
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Hashing of line %d (%q) failed: %s\n", lineno, line, err)
 			os.Exit(1)
 			return
 		}
 
-		r = r[0 : len(r)-1]
-
 		if echoownername {
 			fmt.Printf("; %s\n", line)
 		}
@@ -106,5 +211,21 @@ func main() {
 		os.Exit(1)
 	}
 
+	if cache != nil {
+		s := cache.Stats()
+		fmt.Fprintf(os.Stderr, "Cache: %d hits, %d misses (%.1f%% hit rate), %d evictions, %d entries\n", s.Hits, s.Misses, s.HitRate()*100, s.Evictions, s.Entries)
+	}
+
 	return
 }
+
+// parsePrefix parses an address or prefix, an address is the prefix of only that address
+func parsePrefix(s string) (prefix netip.Prefix, err error) {
+	addr, err := netip.ParseAddr(s)
+	if err == nil {
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+		return
+	}
+
+	return netip.ParsePrefix(s)
+}
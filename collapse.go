@@ -0,0 +1,81 @@
+package hashedrpz
+
+// Collapsed-tail mode, hashing the labels of a too long name that do not fit as a single label.
+
+// HashCollapsed calls Hash() but when the ownername would exceed 255 octets, it hashes
+// all the labels that do not fit together as a single collapsed label in front of the labels that fit.
+//
+// Unlike HashWildcard this does not block the siblings of the name, the result stays an exact
+// match, which resolvers can compute with AppendHashCollapsedWire (or HashCollapsed) for a query name.
+// The collapsed label is of the default size of the LabelSize policy, the labels that fit leave room for it.
+//
+// A left hand side starting with a wildcard can not be collapsed, ErrTooLong is then returned like Hash does.
+func (h *HashedRPZ) HashCollapsed(lefthandside string, origindomain string, callback HashCallback) (final string, collapsed bool, err error) {
+	hs := h.get()
+	defer h.put(hs)
+
+	hs.collapse = true
+
+	start, err := hs.hash([]byte(lefthandside), []byte(origindomain), wrapCallback(callback))
+	final, err = string(hs.final[start:]), hs.hashError(err)
+	collapsed = hs.collapsed
+
+	return
+}
+
+// AppendHashCollapsedWire is the resolver side of HashCollapsed: it appends the collapsed form
+// of a query name in wire format to dst, without allocations when dst has enough capacity.
+//
+// Resolvers only need this when the query name does not fit when hashed (AppendHashWire returns ErrTooLong),
+// looking up the result then matches an entry that was hashed with HashCollapsed from the same name.
+func (h *HashedRPZ) AppendHashCollapsedWire(dst []byte, name []byte, origindomain []byte) (out []byte, collapsed bool, err error) {
+	hs := h.get()
+	defer h.put(hs)
+
+	hs.collapse = true
+
+	hs.wire, err = appendPresentation(hs.wire[:0], name)
+	if err != nil {
+		return dst, false, err
+	}
+
+	start, err := hs.hash(hs.wire, origindomain, nil)
+
+	return append(dst, hs.final[start:]...), hs.collapsed, err
+}
+
+// collapseTail prepends the collapsed label to the result h.final[start:] in collapse mode (see HashCollapsed),
+// returning the new start. It is not possible (ok is false) for a left hand side with a wildcard, as that
+// would lose its meaning, or when the collapsed label does not fit.
+//
+// The collapsed label is the hash of the whole left hand side prefixed with a dot, no other label can
+// have that as its input, as labels can not be empty. With domain separation the depth is 0.
+func (h *hasher) collapseTail(lefthandside []byte, start int, maxdomainlen int) (cstart int, ok bool) {
+	if !h.collapse || lefthandside[0] == '*' {
+		return
+	}
+
+	m := h.sizes.Size
+	n := h.enc.EncodedLen(m)
+
+	cstart = start - n
+	if start != len(h.final) {
+		cstart--
+	}
+
+	if len(h.final)-cstart > maxdomainlen {
+		return
+	}
+
+	if start != len(h.final) {
+		h.final[cstart+n] = '.'
+	}
+
+	h.tail = append(append(h.tail[:0], '.'), lefthandside...)
+	h.depth[0] = 0
+
+	h.label(h.final[cstart:cstart+n], h.tail, m)
+	h.collapsed = true
+
+	return cstart, true
+}
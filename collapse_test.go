@@ -0,0 +1,92 @@
+package hashedrpz
+
+// Tests for the collapsed-tail mode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestHashCollapsed checks that names that fit are hashed as with Hash and too long ones are collapsed
+func TestHashCollapsed(t *testing.T) {
+	h := New(testkey)
+
+	for _, tt := range tests {
+		t.Run(tt.Input, func(t *testing.T) {
+			o, collapsed, err := h.HashCollapsed(tt.Input, origindomain, NoCallback)
+
+			if !errors.Is(tt.Error, ErrTooLong) {
+				if !errors.Is(err, tt.Error) || collapsed || (err == nil && o != tt.Output) {
+					t.Errorf("Expected %q (%v) but got: %q (%t, %v)", tt.Output, tt.Error, o, collapsed, err)
+				}
+
+				return
+			}
+
+			exp := "cnnqplc020qneh9lv3okt0v4h4.ptilhs8.11v1t7g.6esbkao.kce9ido.ib563vg.4dlie60.ckn4lb0.kibrgt8.j2lie10.k481ego.2e8lg50.n1lr5g8.qcs689g.klfks3o.m86tq2g.jsheic0.v3009s8.sou3820.vbkvv38.679i40o.bqfs4mpqnia3vm63efg45eg7t0.kj8qsm2gn1o42.1qpnbgg"
+			if err != nil || !collapsed || o != exp {
+				t.Fatalf("Expected %q but got: %q (%t, %v)", exp, o, collapsed, err)
+			}
+
+			// The labels that fit are the same as with Hash
+			if !strings.HasSuffix(tt.Output, o[strings.IndexByte(o, '.'):]) {
+				t.Errorf("Expected %q to end in the labels of %q", o, tt.Output)
+			}
+
+			if l := wireLen([]byte(o + "." + origindomain)); l > maxWireNameLen {
+				t.Errorf("Collapsed result is %d octets", l)
+			}
+
+			// A sibling results in a different collapsed label, thus is not blocked
+			so, _, err := h.HashCollapsed("sibling."+tt.Input[2:], origindomain, NoCallback)
+			if err != nil || so == o || so[strings.IndexByte(so, '.'):] != o[strings.IndexByte(o, '.'):] {
+				t.Errorf("Unexpected sibling %q (%v) for %q", so, err, o)
+			}
+
+			// Resolvers compute the same from the query name
+			labels, _ := ParseName(tt.Input)
+
+			w, wcollapsed, err := h.AppendHashCollapsedWire(nil, toWire(labels), []byte(origindomain))
+			if err != nil || !wcollapsed || string(w) != o {
+				t.Errorf("Expected %q from wire format, got %q (%t, %v)", o, w, wcollapsed, err)
+			}
+
+			// A wildcard can not be collapsed
+			if _, _, err := h.HashCollapsed("*."+tt.Input, origindomain, NoCallback); !errors.Is(err, ErrTooLong) {
+				t.Errorf("Expected error %s for a wildcard, got: %v", ErrTooLong, err)
+			}
+		})
+	}
+
+	return
+}
+
+// TestHashCollapsedSeparation checks the collapsed label in combination with the other parameters
+func TestHashCollapsedSeparation(t *testing.T) {
+	input := tests[len(tests)-1].Input
+
+	for _, p := range []Params{ParamsV1(), ParamsV3()} {
+		c := NewSuffixCache(1000)
+
+		h := New(testkey, WithParams(p))
+		hc := New(testkey, WithParams(p), WithSuffixCache(c))
+
+		exp, _, err := h.HashCollapsed(input, origindomain, NoCallback)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		// Cached, after the plain hashes of the same name
+		for i := 0; i < 2; i++ {
+			hc.Hash(input, origindomain, NoCallback)
+
+			o, collapsed, err := hc.HashCollapsed(input, origindomain, NoCallback)
+			if err != nil || !collapsed || o != exp {
+				t.Errorf("Version %d: expected %q but got %q (%t, %v)", p.Version, exp, o, collapsed, err)
+			}
+		}
+	}
+
+	return
+}
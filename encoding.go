@@ -0,0 +1,217 @@
+package hashedrpz
+
+// Label encodings, how the digest of a label is rendered as a DNS label.
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrInvalidEncoding is returned when parsing an unknown LabelEncoding
+var ErrInvalidEncoding = errors.New("Invalid label encoding")
+
+// ErrInvalidHashedLabel is returned when a label is not a well-formed hashed label in the encoding
+var ErrInvalidHashedLabel = errors.New("Invalid hashed label")
+
+// LabelEncoding selects how the digest of a label is encoded. All encodings
+// are lowercase and have a fixed length for a digest size, see EncodedLen.
+type LabelEncoding int
+
+const (
+	// EncodingBase32Hex is base32hex-lowercase (RFC4648) without padding (the default)
+	EncodingBase32Hex LabelEncoding = iota
+
+	// EncodingHex is lowercase hexadecimal (base16), for filters that only accept hex,
+	// labels are 2 characters per byte of digest, thus at most 31 bytes fit in a label.
+	EncodingHex
+
+	// EncodingBase36 is the digest as a big-endian number in base 36 (```0-9a-z```),
+	// zero-padded to a fixed length. These are the shortest labels, e.g. 25 instead
+	// of 26 characters for a 16 byte digest and 50 instead of 52 for 32 bytes.
+	EncodingBase36
+)
+
+// base36Digits are the digits of EncodingBase36
+const base36Digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// String returns the name of the encoding as used in the canonical string form of the Params
+func (e LabelEncoding) String() string {
+	switch e {
+	case EncodingBase32Hex:
+		return "base32hex"
+
+	case EncodingHex:
+		return "hex"
+
+	case EncodingBase36:
+		return "base36"
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(e))
+}
+
+// ParseLabelEncoding parses the name of an encoding as returned by String
+func ParseLabelEncoding(s string) (e LabelEncoding, err error) {
+	switch s {
+	case "base32hex":
+		e = EncodingBase32Hex
+
+	case "hex":
+		e = EncodingHex
+
+	case "base36":
+		e = EncodingBase36
+
+	default:
+		err = fmt.Errorf("%w: %q", ErrInvalidEncoding, s)
+	}
+
+	return
+}
+
+// Validate checks that the encoding is known
+func (e LabelEncoding) Validate() error {
+	if e < EncodingBase32Hex || e > EncodingBase36 {
+		return fmt.Errorf("%w: %d", ErrInvalidEncoding, int(e))
+	}
+
+	return nil
+}
+
+// EncodedLen returns the length of the label for a digest of size bytes
+func (e LabelEncoding) EncodedLen(size int) int {
+	switch e {
+	case EncodingHex:
+		return hex.EncodedLen(size)
+
+	case EncodingBase36:
+		// The number of base 36 digits needed for 256^size, as log2(36) is irrational this is never exact
+		return int(math.Ceil(float64(size*8) / math.Log2(36)))
+	}
+
+	return noPadHexEncoding.EncodedLen(size)
+}
+
+// encode encodes the digest into dst, which is EncodedLen(len(digest)) long
+func (e LabelEncoding) encode(dst []byte, digest []byte) {
+	switch e {
+	case EncodingHex:
+		hex.Encode(dst, digest)
+
+	case EncodingBase36:
+		// Long division by 36, producing the digits from the right
+		var num [MaxLabelSize]byte
+		n := copy(num[:], digest)
+
+		for i := len(dst) - 1; i >= 0; i-- {
+			rem := 0
+
+			for j := 0; j < n; j++ {
+				cur := rem<<8 | int(num[j])
+				num[j] = byte(cur / 36)
+				rem = cur % 36
+			}
+
+			dst[i] = base36Digits[rem]
+		}
+
+	default:
+		noPadHexEncoding.Encode(dst, digest)
+	}
+
+	return
+}
+
+// Decode returns the digest of a hashed label in this encoding.
+//
+// Only the form as produced by hashing is accepted (thus lowercase and
+// of a length that a digest size encodes to), otherwise an error wrapping
+// ErrInvalidHashedLabel is returned.
+func (e LabelEncoding) Decode(label string) (digest []byte, err error) {
+	// The digest size that results in a label of this length, the lengths increase with the size
+	size := 0
+	for s := 1; s <= MaxLabelSize; s++ {
+		if e.EncodedLen(s) == len(label) {
+			size = s
+			break
+		}
+	}
+
+	if size == 0 {
+		err = fmt.Errorf("%w: length %d is not a %s digest", ErrInvalidHashedLabel, len(label), e)
+		return
+	}
+
+	switch e {
+	case EncodingHex:
+		digest, err = hex.DecodeString(label)
+
+	case EncodingBase36:
+		digest = make([]byte, size)
+
+		for i := 0; i < len(label); i++ {
+			carry := strings.IndexByte(base36Digits, label[i])
+			if carry < 0 {
+				err = fmt.Errorf("%w: invalid character %q", ErrInvalidHashedLabel, label[i])
+				return
+			}
+
+			// digest = digest * 36 + digit
+			for j := size - 1; j >= 0; j-- {
+				cur := int(digest[j])*36 + carry
+				digest[j] = byte(cur)
+				carry = cur >> 8
+			}
+
+			if carry != 0 {
+				err = fmt.Errorf("%w: value too large for %d bytes", ErrInvalidHashedLabel, size)
+				return
+			}
+		}
+
+	default:
+		digest, err = noPadHexEncoding.DecodeString(label)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidHashedLabel, err)
+		return
+	}
+
+	// Only the canonical form, e.g. uppercase hex or base32hex with trailing bits set are not
+	enc := make([]byte, len(label))
+	e.encode(enc, digest)
+
+	if string(enc) != label {
+		err = fmt.Errorf("%w: %q is not in canonical %s form", ErrInvalidHashedLabel, label, e)
+		digest = nil
+	}
+
+	return
+}
+
+// IsHashedLabel returns true when the label is a well-formed hashed label for these
+// parameters, thus in the encoding and of the length of a digest size of the LabelSize policy.
+//
+// This recognises labels in a zone as hashed ones, it can not tell whether a label is the hash of anything.
+func (p Params) IsHashedLabel(label string) bool {
+	if label == "*" {
+		return false
+	}
+
+	digest, err := p.Encoding.Decode(label)
+	if err != nil {
+		return false
+	}
+
+	for _, size := range p.LabelSize.sizes() {
+		if len(digest) == size {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,132 @@
+package hashedrpz
+
+// Tests for the label encodings
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// encodingTests are the test vectors per encoding, using testkey and the V1 defaults,
+// the digests are the same for every encoding (e.g. ```8r4m02g``` is ```46c9600a``` in hex).
+var encodingTests = map[LabelEncoding][]struct {
+	Input  string
+	Output string
+}{
+	EncodingBase32Hex: {
+		{"www.example.com", "qtr7pq8.slhf50h8dgst0.8r4m02g"},
+	},
+	EncodingHex: {
+		{"www.example.com", "d7767ce9.e562f282286c39d0.46c9600a"},
+		{"*.example.net", "*.a4d1ae5850b87041.0eb375c2"},
+		{"jeroen.massar.ch", "9acad0691b806414.cd8f471b31f70d3e.213d1a54"},
+	},
+	EncodingBase36: {
+		{"www.example.com", "1ns74yx.3hkvjqzchxxow.0jn2fwq"},
+		{"*.example.net", "*.2i8cfm62ocwn5.042ue9u"},
+		{"jeroen.massar.ch", "2cqqbm91u15hw.34ja7yde95rum.0980fh0"},
+	},
+}
+
+// TestEncoding checks the test vectors of every encoding and that the labels are recognised
+func TestEncoding(t *testing.T) {
+	for enc, tests := range encodingTests {
+		p := ParamsV1()
+		p.Encoding = enc
+
+		h := New(testkey, WithParams(p))
+
+		for _, tt := range tests {
+			t.Run(enc.String()+"/"+tt.Input, func(t *testing.T) {
+				o, err := h.Hash(tt.Input, origindomain, NoCallback)
+				if err != nil || o != tt.Output {
+					t.Fatalf("Expected %q but got: %q (%v)", tt.Output, o, err)
+				}
+
+				for _, label := range strings.Split(o, ".") {
+					if p.IsHashedLabel(label) != (label != "*") {
+						t.Errorf("Label %q not recognised correctly", label)
+					}
+				}
+			})
+		}
+	}
+
+	return
+}
+
+// TestEncodingRoundTrip checks that every digest size decodes back
+func TestEncodingRoundTrip(t *testing.T) {
+	digest := make([]byte, MaxLabelSize)
+	for i := range digest {
+		digest[i] = byte(0xff - i*7)
+	}
+
+	for _, enc := range []LabelEncoding{EncodingBase32Hex, EncodingHex, EncodingBase36} {
+		for size := 1; size <= MaxLabelSize; size++ {
+			label := make([]byte, enc.EncodedLen(size))
+			enc.encode(label, digest[:size])
+
+			d, err := enc.Decode(string(label))
+			if err != nil || string(d) != string(digest[:size]) {
+				t.Errorf("%s: size %d decoded %q to %x (%v)", enc, size, label, d, err)
+			}
+		}
+
+		// All ones is the largest value, thus checks the padding
+		ones := []byte{0xff, 0xff, 0xff, 0xff}
+		label := make([]byte, enc.EncodedLen(len(ones)))
+		enc.encode(label, ones)
+
+		if d, err := enc.Decode(string(label)); err != nil || string(d) != string(ones) {
+			t.Errorf("%s: decoded %q to %x (%v)", enc, label, d, err)
+		}
+	}
+
+	return
+}
+
+// TestEncodingInvalid checks that malformed labels are rejected
+func TestEncodingInvalid(t *testing.T) {
+	invalid := map[LabelEncoding][]string{
+		EncodingBase32Hex: {"", "8r4m02", "8R4M02G", "8r4m02w", "8r4m02h"},
+		EncodingHex:       {"", "46c9600", "46C9600A", "46c9600g"},
+		EncodingBase36:    {"", "0jn2fw", "0JN2FWQ", "0jn2fw-", "zzzzzzz"},
+	}
+
+	for enc, labels := range invalid {
+		for _, label := range labels {
+			if _, err := enc.Decode(label); !errors.Is(err, ErrInvalidHashedLabel) {
+				t.Errorf("%s: expected error %s for %q, got: %v", enc, ErrInvalidHashedLabel, label, err)
+			}
+		}
+	}
+
+	// A valid encoding, but not a size of the policy
+	if ParamsV1().IsHashedLabel("5dmmrtbr") {
+		t.Errorf("Expected a 5 byte digest not to be a hashed label of the default policy")
+	}
+
+	// Hex does not fit 32 bytes in a label
+	p := ParamsV1()
+	p.Encoding = EncodingHex
+	p.LabelSize = FixedLabelSize(32)
+
+	if err := p.Validate(); !errors.Is(err, ErrInvalidLabelSizePolicy) {
+		t.Errorf("Expected error %s but got: %v", ErrInvalidLabelSizePolicy, err)
+	}
+
+	for _, enc := range []LabelEncoding{EncodingBase32Hex, EncodingHex, EncodingBase36} {
+		e, err := ParseLabelEncoding(enc.String())
+		if err != nil || e != enc {
+			t.Errorf("Expected %s after parsing, got %s (%v)", enc, e, err)
+		}
+	}
+
+	if _, err := ParseLabelEncoding("base64"); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("Expected error %s but got: %v", ErrInvalidEncoding, err)
+	}
+
+	return
+}
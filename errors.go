@@ -0,0 +1,70 @@
+package hashedrpz
+
+// Typed errors detailing where in the left hand side hashing failed.
+
+import (
+	"fmt"
+)
+
+// HashError is returned by Hash (and the other hashing functions returning a string) for ErrEmptySublabel,
+// ErrWildcardNotAtStart and ErrTooLong, detailing which part of the name caused it.
+// The Append variants (e.g. AppendHash) return these errors as-is, thus they do not allocate.
+//
+// Use errors.Is to check for the specific error (e.g. ```errors.Is(err, ErrTooLong)```)
+// or errors.As to get the details.
+type HashError struct {
+	// Err is the error, one of ErrEmptySublabel, ErrWildcardNotAtStart or ErrTooLong
+	Err error
+
+	// Input is the left hand side as it was passed in
+	Input string
+
+	// Name is the left hand side as it was hashed, thus in canonical presentation form
+	// (see EscapeName), with IDNA and case folding applied and without the final dot.
+	Name string
+
+	// Offset is the offset of the offending label in Name
+	Offset int
+
+	// Index is the index of the offending label, the leftmost label being 0
+	Index int
+
+	// Label is the offending label in Name, empty for ErrEmptySublabel.
+	// For ErrTooLong it is the label at which the ownername exceeded 255 octets.
+	Label string
+}
+
+// Error returns the error and where it was found
+func (e *HashError) Error() string {
+	if e.Name != e.Input {
+		return fmt.Sprintf("%s: label %d (%q) at offset %d of %q (hashed form of %q)", e.Err, e.Index, e.Label, e.Offset, e.Name, e.Input)
+	}
+
+	return fmt.Sprintf("%s: label %d (%q) at offset %d of %q", e.Err, e.Index, e.Label, e.Offset, e.Input)
+}
+
+// Unwrap returns the error, thus errors.Is(err, ErrTooLong) works
+func (e *HashError) Unwrap() error {
+	return e.Err
+}
+
+// newHashError returns a HashError for the label name[start:end] of the left hand side input,
+// name is the left hand side as it is being hashed (see hash).
+func newHashError(err error, input []byte, name []byte, start int, end int) *HashError {
+	index := 0
+
+	for i := 0; i < start; i++ {
+		if name[i] == '.' && !isEscaped(name, i) {
+			index++
+		}
+	}
+
+	return &HashError{
+		Err:    err,
+		Input:  string(input),
+		Name:   string(name),
+		Offset: start,
+		Index:  index,
+		Label:  string(name[start:end]),
+	}
+}
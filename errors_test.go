@@ -0,0 +1,108 @@
+package hashedrpz
+
+// Tests for the typed errors
+
+import (
+	"errors"
+	"testing"
+)
+
+// sameError returns true when both errors are nil or have the same message,
+// as a HashError is created for every call they can not be compared directly.
+func sameError(a error, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Error() == b.Error()
+}
+
+// bareError returns the error of a *HashError, as the Append variants return it
+func bareError(err error) error {
+	var herr *HashError
+	if errors.As(err, &herr) {
+		return herr.Err
+	}
+
+	return err
+}
+
+// TestHashError checks the details of the HashError for the various errors
+func TestHashError(t *testing.T) {
+	h := New(testkey)
+
+	tests := []struct {
+		Input  string
+		Name   string
+		Err    error
+		Index  int
+		Offset int
+		Label  string
+	}{
+		{"empty..sublabel.example.net", "empty..sublabel.example.net", ErrEmptySublabel, 1, 6, ""},
+		{"empty.sublabel..", "empty.sublabel.", ErrEmptySublabel, 2, 15, ""},
+		{"notatstart.*.example.net", "notatstart.*.example.net", ErrWildcardNotAtStart, 1, 11, "*"},
+		{"m*.example.net", "m*.example.net", ErrWildcardNotAtStart, 0, 0, "m*"},
+		{"www.m*.example.net", "www.m*.example.net", ErrWildcardNotAtStart, 1, 4, "m*"},
+		{"a.b.c.d.e.f.g.h.i.j.k.l.m.n.o.p.q.r.s.t.u.v.w.x.y.z.0123456789abcdefghijklmnopqrstuv.example.net", "a.b.c.d.e.f.g.h.i.j.k.l.m.n.o.p.q.r.s.t.u.v.w.x.y.z.0123456789abcdefghijklmnopqrstuv.example.net", ErrTooLong, 2, 4, "c"},
+
+		// The offset and label are those of the canonical form, the escaped dot does not separate labels
+		{"a\\046b..example.net", "a\\.b..example.net", ErrEmptySublabel, 1, 5, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Input, func(t *testing.T) {
+			_, err := h.Hash(tt.Input, origindomain, NoCallback)
+
+			if !errors.Is(err, tt.Err) {
+				t.Fatalf("Expected error %s but got: %v", tt.Err, err)
+			}
+
+			var herr *HashError
+			if !errors.As(err, &herr) {
+				t.Fatalf("Expected a *HashError but got: %T", err)
+			}
+
+			if herr.Input != tt.Input || herr.Name != tt.Name || herr.Index != tt.Index || herr.Offset != tt.Offset || herr.Label != tt.Label {
+				t.Errorf("Expected label %d (%q) at offset %d of %q, got: %s", tt.Index, tt.Label, tt.Offset, tt.Name, herr)
+			}
+		})
+	}
+
+	// The offset is that of the A-label form, thus Name is needed to find the label
+	p := ParamsV1()
+	p.IDNA = IDNAMap
+
+	hi := New(testkey, WithParams(p))
+
+	_, err := hi.Hash("ü..example", origindomain, NoCallback)
+
+	var herr *HashError
+	if !errors.As(err, &herr) || herr.Name != "xn--tda..example" || herr.Offset != 8 || herr.Name[herr.Offset-1] != '.' {
+		t.Errorf("Expected label 1 (\"\") at offset 8 of %q, got: %v", "xn--tda..example", err)
+	}
+
+	return
+}
+
+// TestHashErrorString checks the readable form
+func TestHashErrorString(t *testing.T) {
+	h := New(testkey)
+
+	_, err := h.Hash("www.m*.example.net", origindomain, NoCallback)
+
+	exp := `Wildcard (*) not at start of left hand side: label 1 ("m*") at offset 4 of "www.m*.example.net"`
+	if err == nil || err.Error() != exp {
+		t.Errorf("Expected %q but got: %v", exp, err)
+	}
+
+	// The hashed form is shown when it differs from the input
+	_, err = h.Hash(`www.m*.ex\097mple.net`, origindomain, NoCallback)
+
+	exp = `Wildcard (*) not at start of left hand side: label 1 ("m*") at offset 4 of "www.m*.example.net" (hashed form of "www.m*.ex\\097mple.net")`
+	if err == nil || err.Error() != exp {
+		t.Errorf("Expected %q but got: %v", exp, err)
+	}
+
+	return
+}
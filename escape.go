@@ -0,0 +1,204 @@
+package hashedrpz
+
+// Presentation format (RFC1035 section 5.1) escapes, thus ```\.```, ```\\``` and ```\DDD``` inside names.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidEscape is returned when a name contains an invalid escape (e.g. ```\256``` or a trailing ```\```)
+var ErrInvalidEscape = errors.New("Invalid escape in presentation format name")
+
+// appendCanonical appends the canonical form of a name in presentation format to dst.
+//
+// The escapes are decoded and only the characters that are special while hashing are
+// escaped again: a dot inside a label (```\.```), an asterisk that is not a wildcard (```\*```)
+// and the backslash itself. Thus ```a\046b``` and ```a\.b``` have the same canonical form ```a\.b```,
+// ```a\098c``` becomes ```abc``` and names without escapes are their own canonical form.
+func appendCanonical(dst []byte, name []byte) ([]byte, error) {
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+
+		if c != '\\' {
+			dst = append(dst, c)
+			continue
+		}
+
+		i++
+		if i == len(name) {
+			return dst, fmt.Errorf("%w: trailing backslash", ErrInvalidEscape)
+		}
+
+		c = name[i]
+
+		// \DDD is a decimal byte value, otherwise the character itself
+		if isDigit(c) {
+			if i+2 >= len(name) || !isDigit(name[i+1]) || !isDigit(name[i+2]) {
+				return dst, fmt.Errorf("%w: \\DDD needs three digits", ErrInvalidEscape)
+			}
+
+			v := int(c-'0')*100 + int(name[i+1]-'0')*10 + int(name[i+2]-'0')
+			if v > 255 {
+				return dst, fmt.Errorf("%w: \\%03d is out of range", ErrInvalidEscape, v)
+			}
+
+			c = byte(v)
+			i += 2
+		}
+
+		if c == '.' || c == '\\' || c == '*' {
+			dst = append(dst, '\\')
+		}
+
+		dst = append(dst, c)
+	}
+
+	return dst, nil
+}
+
+// isDigit returns true for the decimal digits
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isEscaped returns true when the character at i is escaped, thus preceded by an odd number of backslashes
+func isEscaped(name []byte, i int) bool {
+	n := 0
+
+	for i--; i >= 0 && name[i] == '\\'; i-- {
+		n++
+	}
+
+	return n%2 == 1
+}
+
+// unescapedLen returns the length in bytes of a label in canonical form, thus counting ```\.``` as one
+func unescapedLen(label []byte) (n int) {
+	for i := 0; i < len(label); i++ {
+		if label[i] == '\\' {
+			i++
+		}
+
+		n++
+	}
+
+	return
+}
+
+// lastSeparator returns the offset of the last dot before end that separates labels, or -1 when there is none.
+func lastSeparator(name []byte, end int) int {
+	for i := end - 1; i >= 0; i-- {
+		if name[i] == '.' && !isEscaped(name, i) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ParseName parses a name in presentation format into its labels (the raw bytes),
+// e.g. ```a\.b.example.``` results in the labels ```a.b``` and ```example```.
+//
+// Returns an error wrapping ErrInvalidEscape for an invalid escape
+// and ErrEmptySublabel when the name contains an empty label.
+func ParseName(name string) (labels [][]byte, err error) {
+	canonical, err := appendCanonical(nil, []byte(name))
+	if err != nil {
+		return
+	}
+
+	// The trailing dot of a fully qualified name
+	if l := len(canonical); l > 0 && canonical[l-1] == '.' && !isEscaped(canonical, l-1) {
+		canonical = canonical[:l-1]
+	}
+
+	for end := len(canonical); end > 0; {
+		start := lastSeparator(canonical, end) + 1
+
+		if start == end {
+			err = ErrEmptySublabel
+			return
+		}
+
+		// Remove the escapes, the canonical form only has \., \\ and \*
+		var label []byte
+
+		for i := start; i < end; i++ {
+			if canonical[i] == '\\' {
+				i++
+			}
+
+			label = append(label, canonical[i])
+		}
+
+		labels = append([][]byte{label}, labels...)
+
+		end = start - 1
+		if end == 0 {
+			err = ErrEmptySublabel
+			return
+		}
+	}
+
+	return
+}
+
+// FormatName renders the labels in presentation format, escaping where needed:
+// ```. \ " ( ) ; @ $ *``` are escaped with a backslash and all other characters that
+// are not printable ASCII (including the space) as ```\DDD```.
+//
+// A label that only consists of an asterisk is rendered as a wildcard (```*```),
+// an asterisk inside another label is escaped as it is not a wildcard.
+// Hashed labels are base32hex and thus never need escaping.
+func FormatName(labels [][]byte) string {
+	var b strings.Builder
+
+	for i, label := range labels {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+
+		if bytes.Equal(label, []byte("*")) {
+			b.WriteByte('*')
+			continue
+		}
+
+		for _, c := range label {
+			switch {
+			case bytes.IndexByte([]byte(`.\"();@$*`), c) >= 0:
+				b.WriteByte('\\')
+				b.WriteByte(c)
+
+			case c <= ' ' || c >= 0x7f:
+				fmt.Fprintf(&b, "\\%03d", c)
+
+			default:
+				b.WriteByte(c)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// EscapeName renders a name in presentation format (e.g. a plaintext input) with proper escapes,
+// thus ```a\046b.example``` becomes ```a\.b.example``` and ```a b.example``` becomes ```a\032b.example```.
+//
+// A trailing dot is kept, see ParseName for the errors.
+func EscapeName(name string) (escaped string, err error) {
+	labels, err := ParseName(name)
+	if err != nil {
+		return
+	}
+
+	escaped = FormatName(labels)
+
+	if strings.HasSuffix(name, ".") && !isEscaped([]byte(name), len(name)-1) {
+		escaped += "."
+	}
+
+	return
+}
@@ -0,0 +1,99 @@
+package hashedrpz
+
+// Tests for the presentation format escapes
+
+import (
+	"errors"
+	"testing"
+)
+
+// escapetests are the test vectors for escaped names, equivalent spellings result in the same output
+var escapetests = []htest{
+	{`a\.b.example.com`, "kt64v3g.slhf50h8dgst0.8r4m02g", nil, nil, 3},
+	{`a\046b.example.com.`, "kt64v3g.slhf50h8dgst0.8r4m02g", nil, nil, 3},
+	{`\119ww.example.com`, "qtr7pq8.slhf50h8dgst0.8r4m02g", nil, nil, 3},
+	{`a\032b.example.com`, "s00vqu0.slhf50h8dgst0.8r4m02g", nil, nil, 3},
+	{`a b.example.com`, "s00vqu0.slhf50h8dgst0.8r4m02g", nil, nil, 3},
+	{`a\\b.example.com`, "ne9t0lg.slhf50h8dgst0.8r4m02g", nil, nil, 3},
+	{`a\092b.example.com`, "ne9t0lg.slhf50h8dgst0.8r4m02g", nil, nil, 3},
+	// An escaped asterisk is not a wildcard
+	{`\*.example.com`, "7srpd8o.slhf50h8dgst0.8r4m02g", nil, nil, 3},
+	{`x.\*.example.com`, "1hc4md8.7srpd8o.slhf50h8dgst0.8r4m02g", nil, nil, 4},
+	// An escaped trailing dot is part of the label
+	{`example.com\.`, "08hh37qlhu1rm.0dtplbt4j7hh8", nil, nil, 2},
+	{`example.com\\.`, "sq21o87u8p96m.vvp7m2eo5o87m", nil, nil, 2},
+	// The digest size follows the length of the label, not of its escaped form
+	{`a\.\.\..com`, "mkk3ajt064o46.8r4m02g", nil, nil, 2},
+	{`\.\.\.\.\.\.\.\.\..com`, "lirt1d2rfeitq1oo2r0nquln70.8r4m02g", nil, nil, 2},
+	{`a\256.com`, "", ErrInvalidEscape, ErrInvalidEscape, 0},
+	{`a\1.com`, "", ErrInvalidEscape, ErrInvalidEscape, 0},
+	{`a\`, "", ErrInvalidEscape, ErrInvalidEscape, 0},
+	{`.`, "", ErrEmptyLabel, ErrEmptyLabel, 0},
+	{`..`, "", ErrEmptySublabel, ErrEmptySublabel, 0},
+}
+
+// TestHashEscapes checks the test vectors for escaped names
+func TestHashEscapes(t *testing.T) {
+	h := New(testkey)
+
+	for _, tt := range escapetests {
+		n := 0
+
+		o, err := h.Hash(tt.Input, origindomain, func(subdomain string, hash string) {
+			n++
+		})
+		if !errors.Is(err, tt.Error) || o != tt.Output {
+			t.Errorf("Expected %q (%v) for %q but got: %q (%v)", tt.Output, tt.Error, tt.Input, o, err)
+		}
+
+		if n != tt.NumCallBacks {
+			t.Errorf("Expected %d callbacks for %q but got: %d", tt.NumCallBacks, tt.Input, n)
+		}
+	}
+
+	r, err := h.HashResult(`www.a\046b.example.com`, origindomain)
+	if err != nil || r.Depth() != 4 || r.Labels[1].Suffix != `a\.b.example.com` || r.Labels[1].Hash != "kt64v3g" {
+		t.Errorf("Unexpected result %+v (%v)", r, err)
+	}
+
+	return
+}
+
+// TestEscapeName checks the rendering of names in presentation format
+func TestEscapeName(t *testing.T) {
+	checks := []struct {
+		Input  string
+		Output string
+		Error  error
+	}{
+		{`a\.b.example.com.`, `a\.b.example.com.`, nil},
+		{`a\046b.Example.com`, `a\.b.Example.com`, nil},
+		{`a b.example`, `a\032b.example`, nil},
+		{"b\xc3\xbccher.example", `b\195\188cher.example`, nil},
+		{`"x";.@$()`, `\"x\"\;.\@\$\(\)`, nil},
+		{`*.example`, `*.example`, nil},
+		{`\*.example`, `*.example`, nil},
+		{`a\*b.example`, `a\*b.example`, nil},
+		{`a..b`, "", ErrEmptySublabel},
+		{`.a`, "", ErrEmptySublabel},
+		{`a\300`, "", ErrInvalidEscape},
+	}
+
+	for _, c := range checks {
+		o, err := EscapeName(c.Input)
+		if !errors.Is(err, c.Error) || o != c.Output {
+			t.Errorf("Expected %q (%v) for %q but got: %q (%v)", c.Output, c.Error, c.Input, o, err)
+		}
+	}
+
+	labels, err := ParseName(`a\.b.c\\d.`)
+	if err != nil || len(labels) != 2 || string(labels[0]) != "a.b" || string(labels[1]) != `c\d` {
+		t.Errorf("Unexpected labels %q (%v)", labels, err)
+	}
+
+	if s := FormatName(labels); s != `a\.b.c\\d` {
+		t.Errorf("Expected %q, got %q", `a\.b.c\\d`, s)
+	}
+
+	return
+}
@@ -1,5 +1,13 @@
 module github.com/massar/hashedrpz
 
-go 1.15
+go 1.18
 
-require github.com/zeebo/blake3 v0.1.0
+require (
+	github.com/zeebo/blake3 v0.2.4
+	golang.org/x/net v0.17.0
+)
+
+require (
+	github.com/klauspost/cpuid/v2 v2.0.12 // indirect
+	golang.org/x/text v0.13.0 // indirect
+)
@@ -4,11 +4,11 @@ package hashedrpz
 // See the README.md and the presentation included in this repository for more details.
 
 import (
+	"bytes"
 	"encoding/base32"
+	"encoding/binary"
 	"errors"
 	"sync"
-
-	"github.com/zeebo/blake3"
 )
 
 // ErrInvalidOriginDomain is returned when the provided is empty, the root (.) or has a leading dot.
@@ -24,12 +24,10 @@ var ErrWildcardNotAtStart = errors.New("Wildcard (*) not at start of left hand s
 // the result from the Hash function is that final only contains the hashed
 // labels upto that error. The caller can decide to wildcard the domain or not.
 //
-// This is used for a very simple check that just checks that we will never exceed
-// the maximum domainlength; though, one already has to substract the $ORIGIN of
-// the domain (e.g. ```.rpz.example.net```) that this RPZ ownername is part of.
-// Thus typically, eg. given ```.rpz.example.net``` of length 14, it already becomes
-// 255-16-15 = 224; hence why 200 is normally the suggested value, but one can
-// accurately guess this value given the origin.
+// The ownername, thus the hashed labels and the $ORIGIN of the zone (e.g. ```rpz.example.net```)
+// that this RPZ ownername is part of, can be at most 255 octets in wire format (RFC1035).
+// Only when it would exceed that ErrTooLong is returned; final then contains the hashed
+// labels that still fit when prefixed with a wildcard (```*.```), see HashWildcard.
 var ErrTooLong = errors.New("Domain too long to hash")
 
 // ErrEmptySublabel is returned when a situation like "dom..example.com" is encountered
@@ -41,10 +39,124 @@ const encodeHexLowerCase = "0123456789abcdefghijklmnopqrstuv"
 // noPadHexEncoding is our base32 encoder
 var noPadHexEncoding = base32.NewEncoding(encodeHexLowerCase).WithPadding(base32.NoPadding)
 
-// HashedRPZ represents a hasher, it has a mutex to ensure only a single caller at a time
+// finalBufSize is the size of the buffer in which the final result is constructed.
+//
+// This is the maximum length of a domain (255) plus room for a single label that
+// overshoots the maximum length (upto 63 characters, see Params.Validate, and a dot) and a wildcard.
+const finalBufSize = 255 + 64 + 2
+
+// HashedRPZ represents a hasher, it is safe for concurrent use.
+//
+// Every call takes a hasher from a pool, thus a single HashedRPZ can be shared
+// between all goroutines. Copies of a HashedRPZ share the same pool.
 type HashedRPZ struct {
-	sync.Mutex
-	h *blake3.Hasher
+	pool *sync.Pool
+
+	// params are the scheme parameters in use
+	params *Params
+}
+
+// Option configures a HashedRPZ, see New
+type Option func(*options)
+
+// options are the settings of a HashedRPZ as configured by the Options given to New
+type options struct {
+	params Params
+
+	// strict enables strict validation with the chars rule, see WithStrict
+	strict bool
+	chars  CharRule
+
+	// cache is the SuffixCache to use, see WithSuffixCache
+	cache *SuffixCache
+}
+
+// WithParams selects the scheme parameters, when not provided DefaultParams() are used.
+//
+// Options are applied in order, thus a WithLabelSizePolicy after WithParams
+// changes the LabelSize of these parameters.
+func WithParams(p Params) Option {
+	return func(o *options) {
+		o.params = p
+	}
+}
+
+// WithLabelSizePolicy selects the policy for the digest size of each label,
+// when not provided the one of the scheme parameters (TieredLabelSize by default) is used.
+func WithLabelSizePolicy(p LabelSizePolicy) Option {
+	return func(o *options) {
+		o.params.LabelSize = p
+	}
+}
+
+// hasher is the per-call state of a HashedRPZ, it is only used by one caller at a time.
+type hasher struct {
+	h keyedHash
+
+	// sizes is the label size policy of the HashedRPZ
+	sizes *LabelSizePolicy
+
+	// fold lowercases the left hand side before hashing (Params.CaseFold)
+	fold bool
+
+	// idna converts the left hand side to A-labels before hashing (Params.IDNA)
+	idna IDNAMode
+
+	// canonical is where a left hand side with escapes is put in canonical form
+	canonical []byte
+
+	// wire is where a wire format name is converted to presentation format
+	wire []byte
+
+	// strict validates the names with the chars rule before hashing (WithStrict)
+	strict bool
+	chars  CharRule
+
+	// lower is where a left hand side with uppercase letters is lowercased
+	lower []byte
+
+	// enc is the encoding of the labels (Params.Encoding)
+	enc LabelEncoding
+
+	// separate enables domain separation (Params.DomainSeparation), bound is
+	// the length-prefixed origin and depth the depth of the label being hashed
+	separate bool
+	bound    []byte
+	depth    [1]byte
+
+	// collapse selects collapse mode (HashCollapsed), tail is where the input of the
+	// collapsed label is constructed and collapsed is true when the result has one
+	collapse  bool
+	tail      []byte
+	collapsed bool
+
+	// trigger is the label of a policy trigger (e.g. rpz-ip, see HashIP) that ends the left hand side,
+	// it is kept verbatim in the result, while the labels in front of it are hashed including it,
+	// triggername is where the left hand side of the trigger is constructed
+	trigger     []byte
+	triggername []byte
+
+	// record enables recording where the labels are in final (HashForOrigins),
+	// input and name are the left hand side as passed in and as hashed,
+	// errstart and errend are where the label of the last error is in name (see hashError)
+	record   bool
+	records  []labelRecord
+	input    []byte
+	name     []byte
+	errstart int
+	errend   int
+
+	// cache is the optional SuffixCache (WithSuffixCache), the entries are keyed by the fingerprint
+	// of the key, the encoding, the digest size and the suffix, constructed in cachekey
+	cache       *SuffixCache
+	fingerprint []byte
+	cachekey    []byte
+
+	// sum is where the digest is stored, avoiding allocations per label
+	sum [32]byte
+
+	// final is where the result is constructed, from the right to the left
+	final [finalBufSize]byte
 }
 
 // HashCallback is called by Hash after each sublabel has been hashed allowing
@@ -55,23 +167,49 @@ type HashCallback func(subdomain string, hash string)
 // (opposed to having a 'nil' and having to check what that nil is for)
 var NoCallback HashCallback = nil
 
+// WalkFunc is called by Walk after each sublabel has been hashed, just like HashCallback,
+// but its return value controls the walk:
+//
+//   - nil continues with the next label
+//   - ErrStopWalk stops the walk
+//   - any other error stops the walk and is returned by Walk
+type WalkFunc func(subdomain string, hash string) error
+
+// ErrStopWalk can be returned by a WalkFunc to stop the walk, e.g. when an intermediate lookup matched.
+// Walk then returns it too, thus the caller knows that the result is partial.
+var ErrStopWalk = errors.New("Walk stopped by callback")
+
+// hashCallback is the internal variant of WalkFunc, it is passed the slices
+// as used by hash, these are only valid for the duration of the call.
+type hashCallback func(subdomain []byte, final []byte) error
+
 // Hash hashes the lefthandside that should be in domain format (thus ```host.example.org```)
 // and returns the HashedRPZ hashed variant of that.
 //
 // Lefthandside is allowed to end to be fully qualified (ending in a '.') but it will be ignored.
 //
+// Lefthandside is in presentation format (RFC1035), thus an escaped dot (```a\.b```) is part of the
+// label and ```\DDD``` escapes are decoded; equivalent spellings of a name result in the same output.
+//
 // The origindomain (e.g. ```rpz.example.com```) is supplied to limit the
 // length of the resulting ownername to ensure it does not exceed the full
 // length of a domain name.
 //
-// The origindomain is not used for hashing, only for limiting/detecting length issues.
+// Without domain separation (scheme versions 1 and 2) the origindomain is not used for hashing,
+// only for limiting/detecting length issues. With domain separation (Params.DomainSeparation,
+// the default of version 3) the origin and the depth of every label are bound into its hash,
+// thus the same name hashes differently in every zone.
 //
-// A mutex ensures that only one hasher at the same time runs
-// Create multiple HashedRPZ, e.g. one per go process, for parallel operation.
+// Hash can be called concurrently from multiple goroutines on the same HashedRPZ,
+// there is no need to create one per go process for parallel operation.
 //
 // The callback will be called for every hashed label, thus allowing the user to do intermediate lookups.
 // One can use a function closure to pass parameters that the callback might need.
 //
+// With case folding (see Params.CaseFold) the left hand side is lowercased before hashing,
+// the subdomain passed to the callback is then the lowercased one.
+// Likewise with IDNA (see Params.IDNA) the subdomain is in A-label form.
+//
 // Will return ErrInvalidOriginDomain if the origin domain is empty or root, or start with a '.'.
 //
 // Will return ErrEmptyLabel if the label to hash is empty, this to avoid blocking the root of DNS.
@@ -82,21 +220,137 @@ var NoCallback HashCallback = nil
 // thus do check for error returns.
 //
 // Will return ErrEmptySubLabel if an empty sublabel is found.
+//
+// ErrWildcardNotAtStart, ErrTooLong and ErrEmptySublabel are returned as a *HashError
+// detailing the offending label, thus check for them with errors.Is.
+//
+// Will return an error wrapping ErrInvalidEscape when the left hand side contains an invalid escape.
+//
+// With strict validation (see WithStrict) a *ValidationError is returned for names that can not exist in the DNS.
+//
+// Will return an error wrapping ErrIDNA when IDNA is enabled and the conversion to A-labels failed.
 func (h *HashedRPZ) Hash(lefthandside string, origindomain string, callback HashCallback) (final string, err error) {
+	// Take a hasher, to ensure we do not use the blake3 hasher recursively from multiple goprocs
+	hs := h.get()
+	defer h.put(hs)
+
+	start, err := hs.hash([]byte(lefthandside), []byte(origindomain), wrapCallback(callback))
+	final, err = string(hs.final[start:]), hs.hashError(err)
+
+	return
+}
+
+// wrapCallback wraps a HashCallback for hash, this only converts to strings when a callback is requested
+func wrapCallback(callback HashCallback) (cb hashCallback) {
+	if callback != nil {
+		cb = func(subdomain []byte, final []byte) error {
+			callback(string(subdomain), string(final))
+			return nil
+		}
+	}
+
+	return
+}
+
+// AppendHash is the allocation-free variant of Hash, it appends the hashed lefthandside
+// to dst and returns the extended buffer.
+//
+// The output, including any partial output in combination with an error, is
+// exactly what Hash would have returned for the same input, thus with domain
+// separation (version 3) the origindomain is bound into the hash as well.
+//
+// When dst has enough capacity (a domain never exceeds 255 characters, thus that is
+// a good size to start with) no allocations are made; which makes this function
+// suitable for hashing large amounts of domains.
+//
+// See Hash for the errors that can be returned, except that ErrWildcardNotAtStart, ErrTooLong and
+// ErrEmptySublabel are returned as-is instead of as a *HashError, thus ErrTooLong does not allocate either.
+func (h *HashedRPZ) AppendHash(dst []byte, lefthandside []byte, origindomain []byte) ([]byte, error) {
+	// Take a hasher, to ensure we do not use the blake3 hasher recursively from multiple goprocs
+	hs := h.get()
+	defer h.put(hs)
+
+	start, err := hs.hash(lefthandside, origindomain, nil)
+
+	return append(dst, hs.final[start:]...), err
+}
+
+// get takes a hasher from the pool, it has to be returned with put.
+func (h *HashedRPZ) get() *hasher {
+	return h.pool.Get().(*hasher)
+}
+
+// put returns a hasher to the pool.
+func (h *HashedRPZ) put(hs *hasher) {
+	hs.collapse = false
+	hs.trigger = hs.trigger[:0]
+	hs.record = false
+	hs.input = nil
+	hs.name = nil
+	h.pool.Put(hs)
+}
+
+// hash is the core of Hash and AppendHash.
+//
+// The result is constructed from the right to the left inside h.final,
+// the returned start is the offset where the result starts, thus
+// h.final[start:] is the result, which is valid till the next call.
+//
+// The hasher is expected to only be used by a single caller.
+func (h *hasher) hash(lefthandside []byte, origindomain []byte, callback hashCallback) (start int, err error) {
+	// Nothing yet
+	start = len(h.final)
+	h.records = h.records[:0]
+
 	// Ensure that the origindomain is not empty or the root or has a leading dot.
-	if origindomain == "" || origindomain == "." || origindomain[0] == '.' {
+	if len(origindomain) == 0 || origindomain[0] == '.' {
 		err = ErrInvalidOriginDomain
 		return
 	}
 
-	// The maximum domain length:
-	// 255 - max ownername length as per RFC1035
-	//  16 - maximum hash length for a label
-	//   1 - the dot separating hash and origindomain
-	//   l - the length of the origindomain
-	//
-	// Noting that the 'spare' 16 bytes when triggered accomodates a '*.' wildcard easily.
-	maxdomainlen := 255 - 16 - 1 - len(origindomain)
+	// The maximum length of the result in presentation format:
+	// 255 - max ownername length in wire format as per RFC1035
+	//   1 - in wire format every label has a length octet, the dots take the
+	//       place of all but the first one
+	//   o - the length of the origindomain in wire format (including the root label)
+	maxdomainlen := maxWireNameLen - 1 - wireLen(origindomain)
+
+	// fit is the start of the longest result that still fits when prefixed with a wildcard,
+	// or in collapse mode with the collapsed label (see HashCollapsed)
+	fit := start
+
+	reserve := 2
+	if h.collapse {
+		reserve = h.enc.EncodedLen(h.sizes.Size) + 1
+	}
+
+	h.collapsed = false
+
+	// The left hand side as passed in, for a HashError
+	input := lefthandside
+
+	// Names in presentation format can contain escapes (e.g. ```a\.b``` or ```a\032b```),
+	// hash their canonical form, in which only \., \\ and \* remain escaped (see appendCanonical)
+	escaped := bytes.IndexByte(lefthandside, '\\') >= 0
+	if escaped {
+		h.canonical, err = appendCanonical(h.canonical[:0], lefthandside)
+		if err != nil {
+			return
+		}
+
+		lefthandside = h.canonical
+	}
+
+	// Resolvers see the A-label form of internationalised names, thus hash that form
+	lefthandside, err = h.idna.toASCII(lefthandside)
+	if err != nil {
+		return
+	}
+
+	// DNS names are case-insensitive, thus hash the canonical (lowercase) form
+	if h.fold {
+		lefthandside = h.foldCase(lefthandside)
+	}
 
 	// Reject encoding an empty label (root effectively) to empty.
 	// Callers likely will want to avoid that situation unless one wants to block the whole Internet...
@@ -106,16 +360,35 @@ func (h *HashedRPZ) Hash(lefthandside string, origindomain string, callback Hash
 	}
 
 	// lhs tracks the left hand side upto the level we are hashing.
-	lhs := len(lefthandside)-1
+	lhs := len(lefthandside) - 1
 
-	// Remove the final dot if it exists
-	if (lefthandside[lhs] == '.') {
+	// Remove the final dot if it exists (and is not an escaped one)
+	if lefthandside[lhs] == '.' && !isEscaped(lefthandside, lhs) {
 		lefthandside = lefthandside[:lhs]
 		lhs--
 
+		// Only the root
+		if lhs < 0 {
+			err = ErrEmptyLabel
+			return
+		}
+
 		// Still got a dot at the end?
-		if (lefthandside[lhs] == '.') {
-			err = ErrEmptySublabel
+		if lefthandside[lhs] == '.' && !isEscaped(lefthandside, lhs) {
+			err = h.fail(ErrEmptySublabel, input, lefthandside, lhs+1, lhs+1)
+			return
+		}
+	}
+
+	// Domain separation binds the origin into every label
+	if h.separate {
+		h.bindOrigin(origindomain)
+	}
+
+	// Strict mode rejects names that can not exist in the DNS
+	if h.strict {
+		err = validateName(lefthandside, h.chars)
+		if err != nil {
 			return
 		}
 	}
@@ -138,17 +411,44 @@ func (h *HashedRPZ) Hash(lefthandside string, origindomain string, callback Hash
 	// Thus  lefthandside[lhs:] gives us 'hand.side'.
 	// while lefthandside[lhs:label] gives us 'hand'.
 	//
+	// The depth of the label, the TLD being 1
+	depth := 0
+
+	// The trigger label is the TLD of the result, the left hand side ends with it (see HashIP)
+	if len(h.trigger) > 0 {
+		t := len(h.trigger)
+
+		if t > maxdomainlen {
+			err = h.fail(ErrTooLong, input, lefthandside, lhs-t+1, lhs+1)
+			return
+		}
+
+		start -= t
+		copy(h.final[start:], h.trigger)
+
+		lhs -= t + 1
+		depth++
+	}
+
 	// We start at the end of the label.
 	label := lhs + 1
 
-	// Lock, to ensure we do not use the blake3 hasher recursively from multiple goprocs
-	h.Lock()
-	defer h.Unlock()
+	// Record the labels for HashForOrigins
+	if h.record {
+		h.input = input
+		h.name = lefthandside
+	}
 
 	// Each label, starting at the TLD (right to left)
 	for i := lhs; i >= 0; i-- {
 		c := lefthandside[i]
 
+		// An escaped dot or asterisk is part of the label
+		if escaped && (c == '.' || c == '*') && isEscaped(lefthandside, i) {
+			lhs = i
+			continue
+		}
+
 		// When no dot yet, this is the left hand side
 		if c != '.' {
 			lhs = i
@@ -159,16 +459,28 @@ func (h *HashedRPZ) Hash(lefthandside string, origindomain string, callback Hash
 		if c == '*' {
 			// Wildcard has to be at the start of the label and the only char in that label
 			if i != 0 || label != lhs+1 {
-				err = ErrWildcardNotAtStart
+				err = h.fail(ErrWildcardNotAtStart, input, lefthandside, lastSeparator(lefthandside, i)+1, label)
 				return
 			}
 
+			if h.record {
+				h.records = append(h.records, labelRecord{start: start - 2, lhs: lhs, end: label})
+			}
+
+			// The wildcard has to fit as well
+			if len(h.final)-start+2 > maxdomainlen {
+				start = fit
+				err = h.fail(ErrTooLong, input, lefthandside, lhs, label)
+				break
+			}
+
 			// No need to hash this further
-			final = "*." + final
+			start -= 2
+			copy(h.final[start:], "*.")
 
 			// Call the callback
 			if callback != nil {
-				callback(lefthandside[lhs:], final)
+				err = callback(lefthandside[lhs:], h.final[start:])
 			}
 
 			// Nothing left (i = 0, thus would break next anyway)
@@ -190,55 +502,72 @@ func (h *HashedRPZ) Hash(lefthandside string, origindomain string, callback Hash
 		//
 		// The output string length (digest length) does not fully disclose
 		// left hand side length, though gives a decent hint.
+		// (see LabelSizePolicy, the default is TieredLabelSize: 4, 8 or 16 bytes)
 		m := label - lhs
 		if m <= 0 {
-			err = ErrEmptySublabel
+			// The empty label is right after this separator
+			err = h.fail(ErrEmptySublabel, input, lefthandside, i+1, i+1)
 			return
-		} else if m < 4 {
-			m = 4
-		} else if m < 8 {
-			m = 8
-		} else {
-			m = 16
 		}
 
-		// Reset what we had upto now
-		h.h.Reset()
+		// The size is based on the length of the label itself, not of its escaped form
+		if escaped {
+			m = unescapedLen(lefthandside[lhs:label])
+		}
 
-		// Hash the current part of the lefthandside
-		h.h.WriteString(lefthandside[lhs:])
+		m = h.sizes.DigestSize(m)
 
-		// Create a buffer for the output hash of the given length
-		hsh := make([]byte, m)
+		// Prepend the hashed label to what we already have.
+		if start != len(h.final) {
+			// Not the first label (the TLD), thus separate it with a dot
+			start--
+			h.final[start] = '.'
+		}
 
-		// Get the digest and store it in the hashed buffer
-		d := h.h.Digest()
-		d.Read(hsh)
+		depth++
+		h.depth[0] = byte(depth)
 
-		// Encode the hash into a base32-hex-lowercase string akin RFC4648
-		b32 := noPadHexEncoding.EncodeToString(hsh)
+		n := h.enc.EncodedLen(m)
+		start -= n
+		h.label(h.final[start:start+n], lefthandside[lhs:], m)
 
-		if final == "" {
-			// First label thus it is the TLD
-			final = b32
-		} else {
-			// Prepend the base32hex-lowercase string
-			final = b32 + "." + final
+		if h.record {
+			h.records = append(h.records, labelRecord{start: start, lhs: lhs, end: label})
 		}
 
 		// Unfortunately, input domains can be very long already e.g. if
 		// there is a hash for a video-id or tracking purposes encoded in them
 		// thus we limit generating very long RPZ elements as they would not
 		// fit in the destination domain.
-		// and replace it with a wildcard; which might mean more gets blocked
+		// and return what fits with a wildcard; which might mean more gets blocked
 		// than needed.
-		if len(final) >= maxdomainlen {
-			err = ErrTooLong
+		if len(h.final)-start > maxdomainlen {
+			start = fit
+			err = h.fail(ErrTooLong, input, lefthandside, lhs, label)
+
+			// Instead hash everything that does not fit as a single label
+			if cstart, ok := h.collapseTail(lefthandside, start, maxdomainlen); ok {
+				start = cstart
+				err = nil
+
+				if callback != nil {
+					err = callback(lefthandside, h.final[start:])
+				}
+			}
+
 			break
 		}
 
+		if len(h.final)-start+reserve <= maxdomainlen {
+			fit = start
+		}
+
 		if callback != nil {
-			callback(lefthandside[lhs:], final)
+			// The callback can stop the walk, leaving the partial result
+			err = callback(lefthandside[lhs:], h.final[start:])
+			if err != nil {
+				break
+			}
 		}
 
 		// The label ends just before the current separator (.)
@@ -251,14 +580,153 @@ func (h *HashedRPZ) Hash(lefthandside string, origindomain string, callback Hash
 	return
 }
 
-// HashWildcard calls Hash() but when the maxdomainlength is exceeded, it encodes
-// the remaining labels as a wildcard inside the domain that fitted.
+// fail records where hashing the left hand side input failed, the label name[start:end]
+// of the name as it was hashed, and returns err as-is. Thus the routine ErrTooLong
+// does not allocate, the *HashError is only created by hashError for the string APIs.
+func (h *hasher) fail(err error, input []byte, name []byte, start int, end int) error {
+	h.input, h.name = input, name
+	h.errstart, h.errend = start, end
+
+	return err
+}
+
+// hashError returns the error of hash as a *HashError when it was recorded by fail, other errors are returned as-is
+func (h *hasher) hashError(err error) error {
+	switch err {
+	case ErrEmptySublabel, ErrWildcardNotAtStart, ErrTooLong:
+		return newHashError(err, h.input, h.name, h.errstart, h.errend)
+	}
+
+	return err
+}
+
+// label hashes the suffix (the current part of the lefthandside) with a digest of m bytes
+// and encodes it into dst, using the SuffixCache when there is one.
+func (h *hasher) label(dst []byte, suffix []byte, m int) {
+	if h.cache != nil {
+		h.cachekey = append(append(h.cachekey[:0], h.fingerprint...), byte(h.enc), byte(m))
+
+		if h.separate {
+			h.cachekey = append(append(h.cachekey, h.bound...), h.depth[:]...)
+		}
+
+		h.cachekey = append(h.cachekey, suffix...)
+
+		// Appending to the empty dst writes into the memory of dst
+		if _, ok := h.cache.get(dst[:0], h.cachekey); ok {
+			return
+		}
+	}
+
+	// Reset what we had upto now
+	h.h.Reset()
+
+	// The origin and depth with domain separation
+	if h.separate {
+		h.h.Write(h.bound)
+		h.h.Write(h.depth[:])
+	}
+
+	// Hash the current part of the lefthandside
+	h.h.Write(suffix)
+
+	// Get the digest, BLAKE3 output is extendable, thus a shorter digest is the prefix
+	// of the full digest, we only use m bytes of it (HMAC-SHA256 is truncated likewise).
+	hsh := h.h.Sum(h.sum[:0])[:m]
+
+	// Encode the hash, by default into a base32-hex-lowercase string akin RFC4648
+	h.enc.encode(dst, hsh)
+
+	if h.cache != nil {
+		h.cache.put(h.cachekey, dst)
+	}
+
+	return
+}
+
+// bindOrigin sets h.bound to the length-prefixed origin for domain separation,
+// the origin is lowercased and a trailing dot removed as these are the same zone.
+func (h *hasher) bindOrigin(origindomain []byte) {
+	if l := len(origindomain); origindomain[l-1] == '.' && !isEscaped(origindomain, l-1) {
+		origindomain = origindomain[:l-1]
+	}
+
+	h.bound = append(h.bound[:0], 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(h.bound, uint32(len(origindomain)))
+
+	for _, c := range origindomain {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+
+		h.bound = append(h.bound, c)
+	}
+
+	return
+}
+
+// foldCase returns the left hand side with the ASCII letters lowercased (RFC4343),
+// other bytes are left as-is. When there are no uppercase letters the left hand
+// side is returned as-is, otherwise it is copied into h.lower and lowercased there.
+func (h *hasher) foldCase(lefthandside []byte) []byte {
+	for i, c := range lefthandside {
+		if c < 'A' || c > 'Z' {
+			continue
+		}
+
+		h.lower = append(h.lower[:0], lefthandside...)
+
+		for j := i; j < len(h.lower); j++ {
+			if c = h.lower[j]; c >= 'A' && c <= 'Z' {
+				h.lower[j] = c + ('a' - 'A')
+			}
+		}
+
+		return h.lower
+	}
+
+	return lefthandside
+}
+
+// Walk hashes the lefthandside like Hash does, but the callback can stop the walk
+// for instance when an intermediate lookup already found a match for ```example.com```,
+// this avoids hashing all the deeper labels of ```www.example.com```.
+//
+// When the callback stops the walk, final contains the result upto and including
+// the label for which the callback was called and err is what the callback returned
+// (ErrStopWalk or its own error). Otherwise the result and errors are the same as for Hash,
+// including the origin and depth being bound into the hash with domain separation (version 3).
+func (h *HashedRPZ) Walk(lefthandside string, origindomain string, callback WalkFunc) (final string, err error) {
+	var cb hashCallback
+
+	if callback != nil {
+		cb = func(subdomain []byte, final []byte) error {
+			return callback(string(subdomain), string(final))
+		}
+	}
+
+	hs := h.get()
+	defer h.put(hs)
+
+	start, err := hs.hash([]byte(lefthandside), []byte(origindomain), cb)
+	final, err = string(hs.final[start:]), hs.hashError(err)
+
+	return
+}
+
+// HashWildcard calls Hash() but when the ownername would exceed 255 octets, it encodes
+// the remaining labels as a wildcard inside the domain that fitted, thus the
+// result always fits within the origin.
 //
 // Thus for example an input of ```host.v.e.r.y.l.o.n.g.example.com``` would
 // encode as ```*.n.g.example.com```.
 // (if the domainname would be much longer than given in this example, see test cases for the real version).
 func (h *HashedRPZ) HashWildcard(lefthandside string, origindomain string, callback HashCallback) (final string, iswildcard bool, err error) {
-	final, err = h.Hash(lefthandside, origindomain, callback)
+	hs := h.get()
+	defer h.put(hs)
+
+	start, err := hs.hash([]byte(lefthandside), []byte(origindomain), wrapCallback(callback))
+	final = string(hs.final[start:])
 
 	// When the string was to long, prefix a wildcard and ignore the error
 	if err == ErrTooLong {
@@ -267,13 +735,94 @@ func (h *HashedRPZ) HashWildcard(lefthandside string, origindomain string, callb
 		err = nil
 	}
 
+	err = hs.hashError(err)
+
 	return
 }
 
+// Params returns the scheme parameters used by this HashedRPZ,
+// the String() of them can be published alongside the zone.
+func (h *HashedRPZ) Params() Params {
+	p := *h.params
+	p.LabelSize.Tiers = append([]SizeTier(nil), p.LabelSize.Tiers...)
+
+	return p
+}
+
 // New creates a new HashedRPZ deriving the BLAKE3 key from the given string
+// (or keying the backend selected by Params.Hash, see HashAlgorithm).
 // The string should be composed of both an inline and a out-of-band key.
-func New(key string) (h HashedRPZ) {
-	// Include a new blake3 hasher
-	h.h = blake3.NewDeriveKey(key)
+//
+// The derived-key state is computed once here, every hasher in the pool
+// is a clone of that state, thus avoiding the key derivation per call.
+//
+// Options (e.g. WithParams or WithLabelSizePolicy) can be provided to change the defaults.
+// New panics when invalid options are provided, thus only use it for known-good constant
+// options (e.g. the built-in Params), use NewWithOptions for options from configuration.
+func New(key string, opts ...Option) (h HashedRPZ) {
+	h, err := NewWithOptions(key, opts...)
+	if err != nil {
+		panic("hashedrpz: " + err.Error())
+	}
+
+	return
+}
+
+// NewWithOptions is New, but returns an error for invalid options instead of panicking,
+// e.g. an error wrapping ErrInvalidParams or ErrInvalidLabelSizePolicy (see Params.Validate).
+func NewWithOptions(key string, opts ...Option) (h HashedRPZ, err error) {
+	o := &options{
+		params: DefaultParams(),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// Copy the tiers, thus later modifications by the caller do not affect us
+	o.params.LabelSize.Tiers = append([]SizeTier(nil), o.params.LabelSize.Tiers...)
+
+	err = o.params.Validate()
+	if err != nil {
+		return
+	}
+
+	err = o.chars.Validate()
+	if err != nil {
+		return
+	}
+
+	h.params = &o.params
+
+	// The keyed hash of the selected backend, the key setup is only done once here
+	newHash := o.params.Hash.newKeyedHash(key)
+
+	// The fingerprint of the key for the SuffixCache, the algorithm and the hash of
+	// the empty string which is never hashed as a label (see ErrEmptyLabel)
+	var fingerprint []byte
+	if o.cache != nil {
+		fingerprint = newHash().Sum([]byte{byte(o.params.Hash)})[:17]
+	}
+
+	h.pool = &sync.Pool{
+		New: func() interface{} {
+			return &hasher{
+				h:     newHash(),
+				sizes: &o.params.LabelSize,
+				fold:  o.params.CaseFold,
+				idna:  o.params.IDNA,
+				enc:   o.params.Encoding,
+
+				separate: o.params.DomainSeparation,
+
+				strict: o.strict,
+				chars:  o.chars,
+
+				cache:       o.cache,
+				fingerprint: fingerprint,
+			}
+		},
+	}
+
 	return
 }
@@ -5,8 +5,10 @@ package hashedrpz
 import (
 	"bufio"
 	"compress/gzip"
+	"errors"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -43,7 +45,7 @@ var tests = []htest{
 	{"m*.example.net", "", ErrWildcardNotAtStart, ErrWildcardNotAtStart, 2},
 	{"empty..sublabel.example.net", "", ErrEmptySublabel, ErrEmptySublabel, 3},
 	{"empty.sublabel..", "", ErrEmptySublabel, ErrEmptySublabel, 0},
-	{"a.b.c.d.e.f.g.h.i.j.k.l.m.n.o.p.q.r.s.t.u.v.w.x.y.z.0123456789abcdefghijklmnopqrstuv.example.net", "*.j5ni418.hv8ls60.ptilhs8.11v1t7g.6esbkao.kce9ido.ib563vg.4dlie60.ckn4lb0.kibrgt8.j2lie10.k481ego.2e8lg50.n1lr5g8.qcs689g.klfks3o.m86tq2g.jsheic0.v3009s8.sou3820.vbkvv38.679i40o.bqfs4mpqnia3vm63efg45eg7t0.kj8qsm2gn1o42.1qpnbgg", ErrTooLong, nil, 24},
+	{"a.b.c.d.e.f.g.h.i.j.k.l.m.n.o.p.q.r.s.t.u.v.w.x.y.z.0123456789abcdefghijklmnopqrstuv.example.net", "*.fhe4qeo.j5ni418.hv8ls60.ptilhs8.11v1t7g.6esbkao.kce9ido.ib563vg.4dlie60.ckn4lb0.kibrgt8.j2lie10.k481ego.2e8lg50.n1lr5g8.qcs689g.klfks3o.m86tq2g.jsheic0.v3009s8.sou3820.vbkvv38.679i40o.bqfs4mpqnia3vm63efg45eg7t0.kj8qsm2gn1o42.1qpnbgg", ErrTooLong, nil, 26},
 }
 
 // TestHash provides a very simple test covering all code paths
@@ -61,7 +63,7 @@ func TestHash(t *testing.T) {
 		t.Run(tt.Input, func(t *testing.T) {
 			o, err := h.Hash(tt.Input, origindomain, NoCallback)
 
-			if err != tt.Error {
+			if !errors.Is(err, tt.Error) {
 				t.Errorf("Expected error %s but got: %s (%q [%d+1+%d=%d])", tt.Error, err, o, len(o), len(origindomain), len(o)+1+len(origindomain))
 				return
 			}
@@ -95,7 +97,7 @@ func TestHashWildcard(t *testing.T) {
 		t.Run(tt.Input, func(t *testing.T) {
 			o, _, err := h.HashWildcard(tt.Input, origindomain, NoCallback)
 
-			if err != tt.ErrorWildcard {
+			if !errors.Is(err, tt.ErrorWildcard) {
 				t.Errorf("Expected error %s but got: %s (%q [%d+1+%d=%d])", tt.Error, err, o, len(o), len(origindomain), len(o)+1+len(origindomain))
 				return
 			}
@@ -149,7 +151,7 @@ func TestHashCallback(t *testing.T) {
 				t.Errorf("Expected %d callbacks, got %d", tt.NumCallBacks, callbacks)
 			}
 
-			if err != tt.Error {
+			if !errors.Is(err, tt.Error) {
 				t.Errorf("Expected error %s but got: %s (%q [%d+1+%d=%d])", tt.Error, err, o, len(o), len(origindomain), len(o)+1+len(origindomain))
 				return
 			}
@@ -168,6 +170,285 @@ func TestHashCallback(t *testing.T) {
 	return
 }
 
+// TestWalk checks that a walk that is not stopped is the same as Hash
+func TestWalk(t *testing.T) {
+	h := New(testkey)
+
+	for _, tt := range tests {
+		t.Run(tt.Input, func(t *testing.T) {
+			callbacks := 0
+
+			o, err := h.Walk(tt.Input, origindomain, func(subdomain string, hash string) error {
+				callbacks++
+				return nil
+			})
+
+			if callbacks != tt.NumCallBacks {
+				t.Errorf("Expected %d callbacks, got %d", tt.NumCallBacks, callbacks)
+			}
+
+			if !errors.Is(err, tt.Error) {
+				t.Errorf("Expected error %s but got: %s", tt.Error, err)
+				return
+			}
+
+			if err == nil && o != tt.Output {
+				t.Errorf("Expected output %q but got: %q", tt.Output, o)
+				return
+			}
+		})
+	}
+
+	return
+}
+
+// TestWalkStop checks that the walk stops and returns the partial result and the reason
+func TestWalkStop(t *testing.T) {
+	h := New(testkey)
+
+	errLookup := errors.New("lookup failed")
+
+	for _, reason := range []error{ErrStopWalk, errLookup} {
+		callbacks := 0
+
+		o, err := h.Walk("www.example.com", origindomain, func(subdomain string, hash string) error {
+			callbacks++
+
+			if subdomain == "example.com" {
+				return reason
+			}
+
+			return nil
+		})
+
+		if err != reason {
+			t.Errorf("Expected error %s but got: %s", reason, err)
+		}
+
+		if callbacks != 2 {
+			t.Errorf("Expected 2 callbacks, got %d", callbacks)
+		}
+
+		if o != "slhf50h8dgst0.8r4m02g" {
+			t.Errorf("Expected partial output %q but got: %q", "slhf50h8dgst0.8r4m02g", o)
+		}
+	}
+
+	return
+}
+
+// TestAppendHash checks that AppendHash produces the exact output of Hash
+func TestAppendHash(t *testing.T) {
+	h := New(testkey)
+
+	t.Run("Empty Origin", func(t *testing.T) {
+		_, err := h.AppendHash(nil, []byte("ignored"), nil)
+		if err != ErrInvalidOriginDomain {
+			t.Errorf("Expected error %s but got: %s", ErrInvalidOriginDomain, err)
+		}
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.Input, func(t *testing.T) {
+			exp, experr := h.Hash(tt.Input, origindomain, NoCallback)
+
+			// Prefix the buffer to check that we really append
+			o, err := h.AppendHash([]byte("prefix:"), []byte(tt.Input), []byte(origindomain))
+
+			// The errors are not a *HashError
+			if err != bareError(experr) {
+				t.Errorf("Expected error %s but got: %s", experr, err)
+				return
+			}
+
+			if string(o) != "prefix:"+exp {
+				t.Errorf("Expected output %q but got: %q", "prefix:"+exp, o)
+				return
+			}
+		})
+	}
+
+	return
+}
+
+// TestAppendHashAllocs verifies that AppendHash does not allocate when given a large enough buffer
+func TestAppendHashAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items under the race detector, thus allocates")
+	}
+
+	h := New(testkey)
+
+	dst := make([]byte, 0, 512)
+	lhs := []byte("www.example.com")
+	origin := []byte(origindomain)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		dst, _ = h.AppendHash(dst[:0], lhs, origin)
+	})
+
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %f", allocs)
+	}
+
+	// Case folding re-uses the buffer of the hasher
+	h = New(testkey, WithParams(ParamsV2()))
+	lhs = []byte("WWW.Example.COM")
+
+	allocs = testing.AllocsPerRun(100, func() {
+		dst, _ = h.AppendHash(dst[:0], lhs, origin)
+	})
+
+	if allocs != 0 {
+		t.Errorf("Expected no allocations with case folding, got %f", allocs)
+	}
+
+	// ErrTooLong is returned as-is, thus a too long name does not allocate either
+	h = New(testkey)
+	lhs = []byte(tests[len(tests)-1].Input)
+
+	allocs = testing.AllocsPerRun(100, func() {
+		dst, _ = h.AppendHash(dst[:0], lhs, origin)
+	})
+
+	if allocs != 0 {
+		t.Errorf("Expected no allocations for a too long name, got %f", allocs)
+	}
+
+	if _, err := h.AppendHash(dst[:0], lhs, origin); err != ErrTooLong {
+		t.Errorf("Expected %s but got: %v", ErrTooLong, err)
+	}
+
+	return
+}
+
+// TestNewWithOptions checks that invalid options result in an error instead of a panic
+func TestNewWithOptions(t *testing.T) {
+	tests := []struct {
+		Opts []Option
+		Err  error
+	}{
+		{nil, nil},
+		{[]Option{WithParams(ParamsV3())}, nil},
+		{[]Option{WithParams(Params{Version: 99, LabelSize: TieredLabelSize})}, ErrUnknownVersion},
+		{[]Option{WithLabelSizePolicy(FixedLabelSize(0))}, ErrInvalidLabelSizePolicy},
+		{[]Option{WithStrict(CharRule(99))}, ErrInvalidCharRule},
+	}
+
+	for _, tt := range tests {
+		h, err := NewWithOptions(testkey, tt.Opts...)
+
+		if tt.Err == nil && err != nil {
+			t.Errorf("Unexpected error: %s", err)
+			continue
+		}
+
+		if !errors.Is(err, tt.Err) {
+			t.Errorf("Expected error %s but got: %v", tt.Err, err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		// The same as New
+		n := New(testkey, tt.Opts...)
+		if h.Params().String() != n.Params().String() {
+			t.Errorf("Expected parameters %s but got: %s", n.Params(), h.Params())
+		}
+	}
+
+	return
+}
+
+// TestHashCaseFold checks that with case folding mixed-case names hash like lowercase ones
+func TestHashCaseFold(t *testing.T) {
+	fold := New(testkey, WithParams(ParamsV2()))
+	v1 := New(testkey)
+
+	checks := []struct {
+		Input  string
+		Output string
+	}{
+		{"www.example.com", "qtr7pq8.slhf50h8dgst0.8r4m02g"},
+		{"WWW.EXAMPLE.COM", "qtr7pq8.slhf50h8dgst0.8r4m02g"},
+		{"wWw.eXaMpLe.CoM.", "qtr7pq8.slhf50h8dgst0.8r4m02g"},
+		{"*.EXAMPLE.Net", "*.kj8qsm2gn1o42.1qpnbgg"},
+	}
+
+	for _, c := range checks {
+		o, err := fold.Hash(c.Input, origindomain, NoCallback)
+		if err != nil || o != c.Output {
+			t.Errorf("Expected %q for %q, got %q (%v)", c.Output, c.Input, o, err)
+		}
+
+		// The lowercase form hashes the same in version 1
+		o, err = v1.Hash(strings.ToLower(c.Input), origindomain, NoCallback)
+		if err != nil || o != c.Output {
+			t.Errorf("Expected %q for %q in version 1, got %q (%v)", c.Output, c.Input, o, err)
+		}
+	}
+
+	// Only ASCII is folded (RFC4343), thus the U+00DC (Ü) is hashed as-is
+	upper, _ := fold.Hash("\u00dcn\u00efcode.example.com", origindomain, NoCallback)
+	lower, _ := fold.Hash("\u00fcn\u00efcode.example.com", origindomain, NoCallback)
+	if upper == lower {
+		t.Errorf("Expected non-ASCII letters not to be folded, got %q for both", upper)
+	}
+
+	// Version 1 does not fold by default, but can be asked to
+	o, err := v1.Hash("WWW.Example.COM", origindomain, NoCallback)
+	if err != nil || o != "ssgompg.8hkdfdq9b9k2u.rv0ch80" {
+		t.Errorf("Expected version 1 to hash %q as-is, got %q (%v)", "WWW.Example.COM", o, err)
+	}
+
+	p := ParamsV1()
+	p.CaseFold = true
+	v1fold := New(testkey, WithParams(p))
+
+	o, err = v1fold.Hash("WWW.Example.COM", origindomain, NoCallback)
+	if err != nil || o != "qtr7pq8.slhf50h8dgst0.8r4m02g" {
+		t.Errorf("Expected version 1 with case folding to hash %q, got %q (%v)", "WWW.Example.COM", o, err)
+	}
+
+	return
+}
+
+// TestHashConcurrent hashes the tests from many goroutines using a single shared HashedRPZ
+func TestHashConcurrent(t *testing.T) {
+	h := New(testkey)
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 100; i++ {
+				for _, tt := range tests {
+					o, err := h.Hash(tt.Input, origindomain, NoCallback)
+					if !errors.Is(err, tt.Error) {
+						t.Errorf("Expected error %s but got: %s", tt.Error, err)
+						return
+					}
+
+					if err == nil && o != tt.Output {
+						t.Errorf("Expected output %q but got: %q", tt.Output, o)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return
+}
+
 // BenchmarkHasher provides a very simple test benchmark
 func BenchmarkHashTests(b *testing.B) {
 	h := New("teststring: eXXV1LwF vINdcL7v sXKtYoo7 EU6Cw2oI lM4Fa0ud 6RShLG9C T7ejeHdT gMaC3zV8")
@@ -192,11 +473,11 @@ func BenchmarkHashTests(b *testing.B) {
 func BenchmarkHashMany(b *testing.B) {
 	h := New("teststring: G8OiYV2A bxzbJv2z eo85UlaA s3Srw0H7 zVm6QSJ5 Uyrbf2mP aczoL4Ft TAc2Suzz")
 
-	const input = "a.b.c.d.e.f.g.h.i.j.k.l.m.n.o.p.q.r.s.t.u.v.w.x.y.z.example.net"
+	const input = "a.b.c.d.e.f.g.h.i.j.k.l.m.n.o.p.q.r.s.t.u.v.w.x.y.z.0123456789abcdefghijklmnopqrstuv.example.net"
 
 	for i := 0; i < b.N; i++ {
 		_, err := h.Hash(input, origindomain, NoCallback)
-		if err != ErrTooLong {
+		if !errors.Is(err, ErrTooLong) {
 			b.Errorf("Failed, expected ErrTooLong, but got: %s", err)
 			return
 		}
@@ -222,6 +503,55 @@ func BenchmarkHashSimple(b *testing.B) {
 	return
 }
 
+// BenchmarkHashParallel hashes a simple domain from all goroutines using a single shared HashedRPZ
+//
+// Run with e.g. '-cpu 1,2,4,8' to see that the throughput scales with GOMAXPROCS.
+func BenchmarkHashParallel(b *testing.B) {
+	h := New("teststring: UuW5RBeL 0OMPAyNe Zj4DbBvy 3aGGLx7V m1nErqPc uXb0Nr9K 8iEvQ2nb Q6RqM0kh")
+
+	const input = "jeroen.massar.ch"
+
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := h.Hash(input, origindomain, NoCallback)
+			if err != nil {
+				b.Errorf("Failed: %s", err)
+				return
+			}
+		}
+	})
+
+	return
+}
+
+// BenchmarkAppendHashParallel is the AppendHash variant of BenchmarkHashParallel
+func BenchmarkAppendHashParallel(b *testing.B) {
+	h := New("teststring: 1JHeF0wo nV2VjX4c xQdVI6Pu pFN1Hg3R kOaP2e8T e7rK1sGb Mwl5VzqJ 3tXo9YhD")
+
+	input := []byte("jeroen.massar.ch")
+	origin := []byte(origindomain)
+
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		dst := make([]byte, 0, 512)
+
+		for pb.Next() {
+			var err error
+
+			dst, err = h.AppendHash(dst[:0], input, origin)
+			if err != nil {
+				b.Errorf("Failed: %s", err)
+				return
+			}
+		}
+	})
+
+	return
+}
+
 // BenchmarkHash10M tests upto 10M entries from the tests/queryfile-example-10million-201202 file
 // as provided by DNS-OARC for their https://github.com/DNS-OARC/dnsperf tool
 // while older, it is a good common set of labels that are in use on the internet
@@ -229,8 +559,18 @@ func BenchmarkHashSimple(b *testing.B) {
 //
 // (this timing also includes reading/processing the file :)
 func BenchmarkHash10M(b *testing.B) {
-	h := New("teststring: 2TIjIdz1 kfxooz7K NjfzpX2I AwJ8UODq 9A2QO8b1 tesMp3Kx Ik4qmDsM fB89XVQe")
+	h := New(bench10Mkey)
+
+	benchmarkHash10M(b, h)
+
+	return
+}
+
+// bench10Mkey is the key used for BenchmarkHash10M
+const bench10Mkey = "teststring: 2TIjIdz1 kfxooz7K NjfzpX2I AwJ8UODq 9A2QO8b1 tesMp3Kx Ik4qmDsM fB89XVQe"
 
+// benchmarkHash10M hashes the entries of the DNS-OARC query file with h, see BenchmarkHash10M
+func benchmarkHash10M(b *testing.B, h HashedRPZ) {
 	testfile := "tests/queryfile-example-10million-201202.gz"
 
 	file, err := os.Open(testfile)
@@ -271,12 +611,12 @@ func BenchmarkHash10M(b *testing.B) {
 		_, err := h.Hash(input, origindomain, NoCallback)
 
 		// Ignore this situation
-		if err == ErrTooLong {
+		if errors.Is(err, ErrTooLong) {
 			toolong++
 			continue
 		}
 
-		if err == ErrWildcardNotAtStart {
+		if errors.Is(err, ErrWildcardNotAtStart) {
 			wrongwildcard++
 			continue
 		}
@@ -301,3 +641,49 @@ func BenchmarkHash10M(b *testing.B) {
 
 	return
 }
+
+// BenchmarkAppendHashMany tests a ownername that is very long using AppendHash
+func BenchmarkAppendHashMany(b *testing.B) {
+	h := New("teststring: G8OiYV2A bxzbJv2z eo85UlaA s3Srw0H7 zVm6QSJ5 Uyrbf2mP aczoL4Ft TAc2Suzz")
+
+	input := []byte("a.b.c.d.e.f.g.h.i.j.k.l.m.n.o.p.q.r.s.t.u.v.w.x.y.z.0123456789abcdefghijklmnopqrstuv.example.net")
+	origin := []byte(origindomain)
+	dst := make([]byte, 0, 512)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var err error
+
+		dst, err = h.AppendHash(dst[:0], input, origin)
+		if !errors.Is(err, ErrTooLong) {
+			b.Errorf("Failed, expected ErrTooLong, but got: %s", err)
+			return
+		}
+	}
+
+	return
+}
+
+// BenchmarkAppendHashSimple tests a simple domain (three labels) using AppendHash
+func BenchmarkAppendHashSimple(b *testing.B) {
+	h := New("teststring: HsDNr7R6 PB5YjqtX p7ICqgN5 NDvpb7Ne wDkGLO33 A2P2lGzw wAb4vytB k09yzzAj")
+
+	input := []byte("jeroen.massar.ch")
+	origin := []byte(origindomain)
+	dst := make([]byte, 0, 512)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var err error
+
+		dst, err = h.AppendHash(dst[:0], input, origin)
+		if err != nil {
+			b.Errorf("Failed: %s", err)
+			return
+		}
+	}
+
+	return
+}
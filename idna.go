@@ -0,0 +1,170 @@
+package hashedrpz
+
+// IDNA support, converting Unicode domain names (U-labels) to their ASCII form (A-labels) before hashing.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrIDNA is returned when the left hand side could not be converted to A-labels (see IDNAMode)
+var ErrIDNA = errors.New("IDNA conversion failed")
+
+// ErrInvalidIDNAMode is returned when parsing an unknown IDNAMode
+var ErrInvalidIDNAMode = errors.New("Invalid IDNA mode")
+
+// IDNAMode selects how Unicode domain names are handled before hashing.
+//
+// Resolvers see the A-label (```xn--```) form of a name, thus a feed entry like
+// ```bücher.example``` only matches when it is hashed as ```xn--bcher-kva.example```.
+type IDNAMode int
+
+const (
+	// IDNANone hashes the bytes of the left hand side as-is (the default)
+	IDNANone IDNAMode = iota
+
+	// IDNAMap converts names containing non-ASCII characters to A-labels using
+	// the UTS#46 (non-transitional, thus IDNA2008) mapping for lookups. Names that
+	// are pure ASCII are hashed as-is, thus names with e.g. underscores keep working.
+	IDNAMap
+
+	// IDNAStrict converts every name like IDNAMap, but also rejects names that are not
+	// valid for lookups per UTS#46 (e.g. invalid ```xn--``` labels, hyphens at the
+	// wrong place or characters other than letters, digits and hyphens).
+	// As the mapping lowercases, all names are then hashed lowercased.
+	IDNAStrict
+)
+
+// idnaMap is the profile for IDNAMap, the mapping of the lookup profile without the STD3 rules
+var idnaMap = idna.New(idna.MapForLookup(), idna.Transitional(false), idna.StrictDomainName(false))
+
+// String returns the name of the mode as used in the canonical string form of the Params
+func (m IDNAMode) String() string {
+	switch m {
+	case IDNANone:
+		return "none"
+
+	case IDNAMap:
+		return "map"
+
+	case IDNAStrict:
+		return "strict"
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(m))
+}
+
+// ParseIDNAMode parses the name of a mode as returned by String
+func ParseIDNAMode(s string) (m IDNAMode, err error) {
+	switch s {
+	case "none":
+		m = IDNANone
+
+	case "map":
+		m = IDNAMap
+
+	case "strict":
+		m = IDNAStrict
+
+	default:
+		err = fmt.Errorf("%w: %q", ErrInvalidIDNAMode, s)
+	}
+
+	return
+}
+
+// Validate checks that the mode is known
+func (m IDNAMode) Validate() error {
+	if m < IDNANone || m > IDNAStrict {
+		return fmt.Errorf("%w: %d", ErrInvalidIDNAMode, int(m))
+	}
+
+	return nil
+}
+
+// toASCII converts the left hand side to A-labels as selected by the mode.
+//
+// A leading wildcard is kept as-is, as ```*``` is not valid in IDNA. When nothing needs
+// converting the left hand side is returned as-is, otherwise a new slice is returned.
+// The conversion is rejected when the mapping removes a label or introduces a wildcard or escape.
+func (m IDNAMode) toASCII(lefthandside []byte) (out []byte, err error) {
+	out = lefthandside
+
+	if m == IDNANone || (m == IDNAMap && isASCII(lefthandside)) || string(lefthandside) == "*" {
+		return
+	}
+
+	profile := idnaMap
+	if m == IDNAStrict {
+		profile = idna.Lookup
+	}
+
+	name := string(lefthandside)
+
+	wildcard := strings.HasPrefix(name, "*.")
+	if wildcard {
+		name = name[2:]
+	}
+
+	mapped, err := profile.ToASCII(name)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrIDNA, err)
+		return
+	}
+
+	// The mapping removes some characters (e.g. the soft hyphen) and maps others to ASCII,
+	// thus reject labels that vanished and a wildcard or escape that was not in the name
+	// (e.g. ```＊.example``` maps to ```*.example```)
+	if emptyLabels(mapped) > emptyLabels(name) || strings.Count(mapped, "*") > strings.Count(name, "*") ||
+		strings.Count(mapped, `\`) > strings.Count(name, `\`) {
+		err = fmt.Errorf("%w: %q maps to %q", ErrIDNA, name, mapped)
+		return
+	}
+
+	if wildcard {
+		mapped = "*." + mapped
+	}
+
+	out = []byte(mapped)
+
+	return
+}
+
+// emptyLabels returns the number of empty labels in the name, including the root label after a final dot,
+// the ideographic and fullwidth full stops separate labels too as the mapping turns them into dots
+func emptyLabels(name string) (n int) {
+	empty := true
+
+	for _, c := range name {
+		if c == '.' || c == '\u3002' || c == '\uff0e' || c == '\uff61' {
+			if empty {
+				n++
+			}
+
+			empty = true
+			continue
+		}
+
+		empty = false
+	}
+
+	if empty {
+		n++
+	}
+
+	return
+}
+
+// isASCII returns true when the name only contains ASCII characters
+func isASCII(name []byte) bool {
+	for _, c := range name {
+		if c >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,135 @@
+package hashedrpz
+
+// Tests for the IDNA conversion
+
+import (
+	"errors"
+	"testing"
+)
+
+type idnatest struct {
+	Input  string
+	Output string
+	Error  error
+}
+
+// idnatests are the test vectors per IDNAMode, bücher.example is xn--bcher-kva.example
+var idnatests = map[IDNAMode][]idnatest{
+	IDNAMap: {
+		{"bücher.example", "4rf8jpn0rv1rffn886kf3nqj3o.n2gntspilc4q8", nil},
+		{"xn--bcher-kva.example", "4rf8jpn0rv1rffn886kf3nqj3o.n2gntspilc4q8", nil},
+		{"BÜCHER.Example.", "4rf8jpn0rv1rffn886kf3nqj3o.n2gntspilc4q8", nil},
+		{"*.bücher.example", "*.4rf8jpn0rv1rffn886kf3nqj3o.n2gntspilc4q8", nil},
+		{"*", "*.", nil},
+		// The ideographic full stop (U+3002) maps to a dot
+		{"münchen。example", "4tt91ln4h2c5spvcspd80dr33k.n2gntspilc4q8", nil},
+		// ASCII names are hashed as-is
+		{"WWW.Example.COM", "ssgompg.8hkdfdq9b9k2u.rv0ch80", nil},
+		{"_dmarc.example.com", "hum6tu71seues.slhf50h8dgst0.8r4m02g", nil},
+		{"-abc.example", "n7eqgesf54bc6.n2gntspilc4q8", nil},
+		{"_dmarc.bücher.example", "snl8qba06onvi.4rf8jpn0rv1rffn886kf3nqj3o.n2gntspilc4q8", nil},
+		{"\u0080.example", "", ErrIDNA},
+		{"", "", ErrEmptyLabel},
+		// Labels must not map to nothing, the soft hyphen (U+00AD) and zero width space (U+200B) do
+		{"\u00ad", "", ErrIDNA},
+		{"*.\u00ad", "", ErrIDNA},
+		{"a.\u00ad", "", ErrIDNA},
+		{"\u200b.com", "", ErrIDNA},
+		{"\u00ad..example", "", ErrIDNA},
+		{"b\u00adücher.example", "4rf8jpn0rv1rffn886kf3nqj3o.n2gntspilc4q8", nil},
+		// Neither a wildcard nor an escape, the fullwidth asterisk (U+FF0A) and reverse solidus (U+FF3C) map to them
+		{"\uff0a.example", "", ErrIDNA},
+		{"a\uff3c.b.example", "", ErrIDNA},
+	},
+	IDNAStrict: {
+		{"bücher.example", "4rf8jpn0rv1rffn886kf3nqj3o.n2gntspilc4q8", nil},
+		{"xn--bcher-kva.example", "4rf8jpn0rv1rffn886kf3nqj3o.n2gntspilc4q8", nil},
+		{"*.bücher.example", "*.4rf8jpn0rv1rffn886kf3nqj3o.n2gntspilc4q8", nil},
+		{"*", "*.", nil},
+		// The mapping lowercases
+		{"WWW.Example.COM", "qtr7pq8.slhf50h8dgst0.8r4m02g", nil},
+		{"_dmarc.example.com", "", ErrIDNA},
+		{"-abc.example", "", ErrIDNA},
+		{"xn--a.example", "", ErrIDNA},
+		{"\u0080.example", "", ErrIDNA},
+		{"a..bücher.de", "fpd62qfvu58qjl3tvshp1lei10.k5sbm48", ErrEmptySublabel},
+		{"", "", ErrEmptyLabel},
+		{"\u00ad", "", ErrIDNA},
+		{"*.\u00ad", "", ErrIDNA},
+		{"a.\u00ad", "", ErrIDNA},
+		{"\u200b.com", "", ErrIDNA},
+		{"\uff0a.example", "", ErrIDNA},
+		{"a\uff3c.b.example", "", ErrIDNA},
+	},
+}
+
+// TestHashIDNA checks the test vectors of the IDNA modes
+func TestHashIDNA(t *testing.T) {
+	for mode, tests := range idnatests {
+		p := ParamsV1()
+		p.IDNA = mode
+
+		h := New(testkey, WithParams(p))
+
+		for _, tt := range tests {
+			o, err := h.Hash(tt.Input, origindomain, NoCallback)
+			if !errors.Is(err, tt.Error) || o != tt.Output {
+				t.Errorf("%s: Expected %q (%v) for %q but got: %q (%v)", mode, tt.Output, tt.Error, tt.Input, o, err)
+			}
+		}
+	}
+
+	// Without IDNA the UTF-8 bytes are hashed, which does not match the A-label form
+	h := New(testkey)
+
+	o, err := h.Hash("bücher.example", origindomain, NoCallback)
+	if err != nil || o != "esdk0uahhj54e.n2gntspilc4q8" {
+		t.Errorf("Expected %q without IDNA, got %q (%v)", "esdk0uahhj54e.n2gntspilc4q8", o, err)
+	}
+
+	return
+}
+
+// TestHashResultIDNA checks that the suffixes of the result are in A-label form
+func TestHashResultIDNA(t *testing.T) {
+	p := ParamsV1()
+	p.IDNA = IDNAMap
+
+	h := New(testkey, WithParams(p))
+
+	r, err := h.HashResult("www.bücher.example", origindomain)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if r.Depth() != 3 || r.Labels[0].Suffix != "www.xn--bcher-kva.example" || r.Labels[1].Suffix != "xn--bcher-kva.example" {
+		t.Errorf("Unexpected result %+v", r)
+	}
+
+	// The fullwidth reverse solidus is not an escape, thus does not split the labels differently
+	if r, err := h.HashResult("a\uff3c.b.example", origindomain); !errors.Is(err, ErrIDNA) {
+		t.Errorf("Expected %s but got: %+v (%v)", ErrIDNA, r, err)
+	}
+
+	return
+}
+
+// TestParseIDNAMode checks that every mode parses back
+func TestParseIDNAMode(t *testing.T) {
+	for _, m := range []IDNAMode{IDNANone, IDNAMap, IDNAStrict} {
+		pm, err := ParseIDNAMode(m.String())
+		if err != nil || pm != m {
+			t.Errorf("Expected %s, got %s (%v)", m, pm, err)
+		}
+	}
+
+	if _, err := ParseIDNAMode("uts46"); !errors.Is(err, ErrInvalidIDNAMode) {
+		t.Errorf("Expected error %s, got: %v", ErrInvalidIDNAMode, err)
+	}
+
+	if err := IDNAMode(3).Validate(); !errors.Is(err, ErrInvalidIDNAMode) {
+		t.Errorf("Expected error %s, got: %v", ErrInvalidIDNAMode, err)
+	}
+
+	return
+}
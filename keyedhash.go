@@ -0,0 +1,102 @@
+package hashedrpz
+
+// Keyed hash backends, the function that produces the digest of every label.
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/zeebo/blake3"
+)
+
+// ErrInvalidHashAlgorithm is returned when parsing an unknown HashAlgorithm
+var ErrInvalidHashAlgorithm = errors.New("Invalid hash algorithm")
+
+// keyedHash is a keyed hash function as used for hashing the labels,
+// the key is set when it is created (see HashAlgorithm).
+//
+// Sum appends the digest of what was written since the last Reset, the digest
+// has to be at least MaxLabelSize bytes; only the prefix needed for a label is used.
+type keyedHash interface {
+	// Write adds more data, it never returns an error
+	Write(p []byte) (n int, err error)
+
+	// Sum appends the digest to b without changing the state
+	Sum(b []byte) []byte
+
+	// Reset resets the state to that of the keyed hash before anything was written
+	Reset()
+}
+
+// HashAlgorithm selects the keyed hash backend used for the labels.
+type HashAlgorithm int
+
+const (
+	// HashBLAKE3 is BLAKE3 in key derivation mode, with the key string as the context (the default)
+	HashBLAKE3 HashAlgorithm = iota
+
+	// HashHMACSHA256 is HMAC-SHA256 (RFC2104, FIPS 198-1) keyed with the bytes of the key string,
+	// for environments that only permit FIPS-approved primitives. The digest is truncated
+	// to the label size, just like the BLAKE3 output is.
+	HashHMACSHA256
+)
+
+// String returns the name of the algorithm as used in the canonical string form of the Params
+func (a HashAlgorithm) String() string {
+	switch a {
+	case HashBLAKE3:
+		return "blake3"
+
+	case HashHMACSHA256:
+		return "hmac-sha256"
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(a))
+}
+
+// ParseHashAlgorithm parses the name of an algorithm as returned by String
+func ParseHashAlgorithm(s string) (a HashAlgorithm, err error) {
+	switch s {
+	case "blake3":
+		a = HashBLAKE3
+
+	case "hmac-sha256":
+		a = HashHMACSHA256
+
+	default:
+		err = fmt.Errorf("%w: %q", ErrInvalidHashAlgorithm, s)
+	}
+
+	return
+}
+
+// Validate checks that the algorithm is known
+func (a HashAlgorithm) Validate() error {
+	if a < HashBLAKE3 || a > HashHMACSHA256 {
+		return fmt.Errorf("%w: %d", ErrInvalidHashAlgorithm, int(a))
+	}
+
+	return nil
+}
+
+// newKeyedHash returns a function creating keyed hashes of the algorithm with the key,
+// the key setup is done once here, thus creating the keyed hashes is cheap.
+func (a HashAlgorithm) newKeyedHash(key string) func() keyedHash {
+	switch a {
+	case HashHMACSHA256:
+		k := []byte(key)
+
+		return func() keyedHash {
+			return hmac.New(sha256.New, k)
+		}
+	}
+
+	// The derived-key state, every hasher is a clone of it
+	base := blake3.NewDeriveKey(key)
+
+	return func() keyedHash {
+		return base.Clone()
+	}
+}
@@ -0,0 +1,70 @@
+package hashedrpz
+
+// Tests for the keyed hash backends
+
+import (
+	"errors"
+	"testing"
+)
+
+// keyedHashTests are the test vectors per backend, using testkey and the V1 defaults,
+// the HMAC-SHA256 ones can be reproduced with any HMAC implementation.
+var keyedHashTests = map[HashAlgorithm][]struct {
+	Input  string
+	Output string
+}{
+	HashBLAKE3: {
+		{"www.example.com", "qtr7pq8.slhf50h8dgst0.8r4m02g"},
+		{"example.com", "slhf50h8dgst0.8r4m02g"},
+		{"com", "8r4m02g"},
+	},
+	HashHMACSHA256: {
+		{"www.example.com", "mcvukfo.mpp62h4c9lp4o.8iknvc0"},
+		{"example.com", "mpp62h4c9lp4o.8iknvc0"},
+		{"com", "8iknvc0"},
+		{"*.example.net", "*.qilfuj4sgfu6i.fq7j0m0"},
+		{"jeroen.massar.ch", "qmfe961fvjl1k.4emug61ug4f78.ii8nl8g"},
+		{"a.very.long.label.abcdefghijklmnopqrstuvwxyz.example.org", "tmr4sbo.0burq70s0impu.h9i36cslm8ik8.t2pjdfarc3e86.0vt7irmi5re65afgc61gcv3d94.ti9kivlkot5hg.b67otfg"},
+	},
+}
+
+// TestKeyedHash checks the test vectors of every backend
+func TestKeyedHash(t *testing.T) {
+	for alg, tests := range keyedHashTests {
+		p := ParamsV1()
+		p.Hash = alg
+
+		h := New(testkey, WithParams(p))
+
+		for _, tt := range tests {
+			t.Run(alg.String()+"/"+tt.Input, func(t *testing.T) {
+				o, err := h.Hash(tt.Input, origindomain, NoCallback)
+				if err != nil || o != tt.Output {
+					t.Errorf("Expected %q but got: %q (%v)", tt.Output, o, err)
+				}
+			})
+		}
+	}
+
+	return
+}
+
+// TestHashAlgorithm checks the names of the algorithms
+func TestHashAlgorithm(t *testing.T) {
+	for _, a := range []HashAlgorithm{HashBLAKE3, HashHMACSHA256} {
+		pa, err := ParseHashAlgorithm(a.String())
+		if err != nil || pa != a {
+			t.Errorf("Expected %s after parsing, got %s (%v)", a, pa, err)
+		}
+	}
+
+	if _, err := ParseHashAlgorithm("md5"); !errors.Is(err, ErrInvalidHashAlgorithm) {
+		t.Errorf("Expected error %s but got: %v", ErrInvalidHashAlgorithm, err)
+	}
+
+	if err := HashAlgorithm(42).Validate(); !errors.Is(err, ErrInvalidHashAlgorithm) {
+		t.Errorf("Expected error %s but got: %v", ErrInvalidHashAlgorithm, err)
+	}
+
+	return
+}
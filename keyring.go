@@ -0,0 +1,184 @@
+package hashedrpz
+
+// Keyring holding multiple keys with validity windows, for rotating keys with overlap.
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrDuplicateKeyID is returned when a key with the same ID is already in the Keyring
+var ErrDuplicateKeyID = errors.New("Duplicate Key ID in Keyring")
+
+// KeyringEntry is a key in a Keyring with its validity window
+type KeyringEntry struct {
+	// ID identifies the key, e.g. the serial of the zone that introduced it
+	ID string
+
+	// Hasher is the HashedRPZ for this key
+	Hasher HashedRPZ
+
+	// NotBefore is the time from which the key is valid, the zero time means always
+	NotBefore time.Time
+
+	// NotAfter is the time from which the key is not valid anymore, the zero time means never
+	NotAfter time.Time
+}
+
+// ValidAt returns true when the key is valid at time t
+func (e *KeyringEntry) ValidAt(t time.Time) bool {
+	if !e.NotBefore.IsZero() && t.Before(e.NotBefore) {
+		return false
+	}
+
+	if !e.NotAfter.IsZero() && !t.Before(e.NotAfter) {
+		return false
+	}
+
+	return true
+}
+
+// Keyring holds the previous, current and next keys of a zone with their validity windows.
+//
+// During a rotation the validity windows of the keys overlap, producers then publish
+// the entries hashed under every valid key and resolvers match against all of them,
+// thus no entries are missed while the zone and the keys propagate.
+//
+// A Keyring is safe for concurrent use.
+type Keyring struct {
+	mu sync.RWMutex
+
+	// entries sorted by NotBefore
+	entries []KeyringEntry
+}
+
+// KeyedResult is the result of hashing with one of the keys of a Keyring
+type KeyedResult struct {
+	// KeyID is the ID of the key that produced this result
+	KeyID string
+
+	// Output is the hashed result, as returned by Hash or HashWildcard
+	Output string
+
+	// IsWildcard indicates that the output was made a wildcard (only with HashWildcard)
+	IsWildcard bool
+
+	// Err is the error of hashing with this key
+	Err error
+}
+
+// NewKeyring creates a new empty Keyring
+func NewKeyring() *Keyring {
+	return &Keyring{}
+}
+
+// Add adds a key to the keyring, valid from notbefore upto notafter (see KeyringEntry).
+//
+// Returns ErrDuplicateKeyID when a key with the same ID is already present.
+func (k *Keyring) Add(id string, h HashedRPZ, notbefore time.Time, notafter time.Time) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, e := range k.entries {
+		if e.ID == id {
+			return ErrDuplicateKeyID
+		}
+	}
+
+	k.entries = append(k.entries, KeyringEntry{ID: id, Hasher: h, NotBefore: notbefore, NotAfter: notafter})
+
+	sort.SliceStable(k.entries, func(i, j int) bool {
+		return k.entries[i].NotBefore.Before(k.entries[j].NotBefore)
+	})
+
+	return nil
+}
+
+// Remove removes the key with the given ID, returns false when it was not present
+func (k *Keyring) Remove(id string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for i, e := range k.entries {
+		if e.ID == id {
+			k.entries = append(k.entries[:i], k.entries[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Expire removes all keys that are not valid anymore at time t and returns how many were removed
+func (k *Keyring) Expire(t time.Time) (n int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	entries := k.entries[:0]
+
+	for _, e := range k.entries {
+		if !e.NotAfter.IsZero() && !t.Before(e.NotAfter) {
+			n++
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	k.entries = entries
+
+	return
+}
+
+// Valid returns the keys valid at time t, ordered by NotBefore (thus the previous key first)
+func (k *Keyring) Valid(t time.Time) (valid []KeyringEntry) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, e := range k.entries {
+		if e.ValidAt(t) {
+			valid = append(valid, e)
+		}
+	}
+
+	return
+}
+
+// Current returns the key valid at time t that became valid last, thus
+// during an overlap the new key; ok is false when no key is valid.
+func (k *Keyring) Current(t time.Time) (current KeyringEntry, ok bool) {
+	valid := k.Valid(t)
+	if len(valid) == 0 {
+		return
+	}
+
+	return valid[len(valid)-1], true
+}
+
+// Hash hashes the lefthandside with every key valid at time t, see HashedRPZ.Hash.
+//
+// The results are in the same order as returned by Valid, no results are returned when no key is valid.
+func (k *Keyring) Hash(lefthandside string, origindomain string, t time.Time) (results []KeyedResult) {
+	for _, e := range k.Valid(t) {
+		r := KeyedResult{KeyID: e.ID}
+		r.Output, r.Err = e.Hasher.Hash(lefthandside, origindomain, NoCallback)
+		results = append(results, r)
+	}
+
+	return
+}
+
+// HashWildcard hashes the lefthandside with every key valid at time t, see HashedRPZ.HashWildcard.
+//
+// The results are in the same order as returned by Valid, no results are returned when no key is valid.
+func (k *Keyring) HashWildcard(lefthandside string, origindomain string, t time.Time) (results []KeyedResult) {
+	for _, e := range k.Valid(t) {
+		r := KeyedResult{KeyID: e.ID}
+		r.Output, r.IsWildcard, r.Err = e.Hasher.HashWildcard(lefthandside, origindomain, NoCallback)
+		results = append(results, r)
+	}
+
+	return
+}
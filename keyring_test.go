@@ -0,0 +1,129 @@
+package hashedrpz
+
+// Tests for the Keyring
+
+import (
+	"testing"
+	"time"
+)
+
+// testKeyring returns a keyring with a previous, current and next key that overlap by an hour
+func testKeyring(t *testing.T, base time.Time) *Keyring {
+	k := NewKeyring()
+
+	keys := []struct {
+		ID        string
+		Key       string
+		NotBefore time.Time
+		NotAfter  time.Time
+	}{
+		{"next", "teststring: next", base.Add(23 * time.Hour), time.Time{}},
+		{"previous", "teststring: previous", time.Time{}, base.Add(time.Hour)},
+		{"current", testkey, base, base.Add(24 * time.Hour)},
+	}
+
+	for _, key := range keys {
+		if err := k.Add(key.ID, New(key.Key), key.NotBefore, key.NotAfter); err != nil {
+			t.Fatalf("Failed to add %q: %s", key.ID, err)
+		}
+	}
+
+	return k
+}
+
+// keyIDs returns the IDs of the entries
+func keyIDs(entries []KeyringEntry) (ids []string) {
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+
+	return
+}
+
+// TestKeyringValid checks which keys are valid during and between the overlaps
+func TestKeyringValid(t *testing.T) {
+	base := time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC)
+	k := testKeyring(t, base)
+
+	checks := []struct {
+		At      time.Time
+		Valid   []string
+		Current string
+	}{
+		{base.Add(-time.Hour), []string{"previous"}, "previous"},
+		{base.Add(30 * time.Minute), []string{"previous", "current"}, "current"},
+		{base.Add(time.Hour), []string{"current"}, "current"},
+		{base.Add(23*time.Hour + 30*time.Minute), []string{"current", "next"}, "next"},
+		{base.Add(48 * time.Hour), []string{"next"}, "next"},
+	}
+
+	for _, c := range checks {
+		valid := keyIDs(k.Valid(c.At))
+
+		if len(valid) != len(c.Valid) {
+			t.Errorf("At %s expected %v to be valid, got %v", c.At, c.Valid, valid)
+			continue
+		}
+
+		for i := range valid {
+			if valid[i] != c.Valid[i] {
+				t.Errorf("At %s expected %v to be valid, got %v", c.At, c.Valid, valid)
+				break
+			}
+		}
+
+		cur, ok := k.Current(c.At)
+		if !ok || cur.ID != c.Current {
+			t.Errorf("At %s expected current %q, got %q", c.At, c.Current, cur.ID)
+		}
+	}
+
+	if err := k.Add("current", New(testkey), base, time.Time{}); err != ErrDuplicateKeyID {
+		t.Errorf("Expected error %s, got: %v", ErrDuplicateKeyID, err)
+	}
+
+	if n := k.Expire(base.Add(2 * time.Hour)); n != 1 {
+		t.Errorf("Expected 1 expired key, got %d", n)
+	}
+
+	if !k.Remove("next") || k.Remove("next") {
+		t.Errorf("Expected next to be removed once")
+	}
+
+	if _, ok := k.Current(base.Add(48 * time.Hour)); ok {
+		t.Errorf("Expected no current key")
+	}
+
+	return
+}
+
+// TestKeyringHash checks that every valid key produces a result with its ID
+func TestKeyringHash(t *testing.T) {
+	base := time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC)
+	k := testKeyring(t, base)
+
+	results := k.Hash("www.example.com", origindomain, base.Add(30*time.Minute))
+	if len(results) != 2 || results[0].KeyID != "previous" || results[1].KeyID != "current" {
+		t.Fatalf("Expected results for previous and current, got %+v", results)
+	}
+
+	hprev := New("teststring: previous")
+	prev, _ := hprev.Hash("www.example.com", origindomain, NoCallback)
+
+	if results[0].Err != nil || results[0].Output != prev {
+		t.Errorf("Expected %q for previous, got %q (%v)", prev, results[0].Output, results[0].Err)
+	}
+
+	if results[1].Err != nil || results[1].Output != "qtr7pq8.slhf50h8dgst0.8r4m02g" {
+		t.Errorf("Expected %q for current, got %q (%v)", "qtr7pq8.slhf50h8dgst0.8r4m02g", results[1].Output, results[1].Err)
+	}
+
+	tt := tests[len(tests)-1]
+
+	results = k.HashWildcard(tt.Input, origindomain, base.Add(2*time.Hour))
+	if len(results) != 1 || results[0].KeyID != "current" || !results[0].IsWildcard || results[0].Output != tt.Output {
+		t.Errorf("Expected wildcard %q for current, got %+v", tt.Output, results)
+	}
+
+	return
+}
@@ -0,0 +1,63 @@
+package hashedrpz
+
+// Combination of the in-band and out-of-band keys into the key used by New.
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/zeebo/blake3"
+)
+
+// KeyCombinationContext is the BLAKE3 DeriveKey context string used by CombineKeys.
+const KeyCombinationContext = "github.com/massar/hashedrpz 2026-10-16 in-band out-of-band key combination v1"
+
+// CombineKeys combines the in-band key (the value of the ```_rpzhashkey``` TXT record),
+// the out-of-band per-zone key and the origin of the zone into the key string for New.
+//
+// The combination is:
+//
+//	material = lp(origin) || lp(inband) || lp(outofband)
+//	key      = lowercase-hex(BLAKE3-DeriveKey(KeyCombinationContext, material)[:32])
+//
+// Where lp(x) is the length of x as a 32 bit big-endian integer followed by x itself,
+// thus no combination of the parts can result in the same material. The origin is
+// lowercased and a trailing dot removed, as ```RPZ.example.net.``` is the same zone as ```rpz.example.net```.
+// Only the ASCII letters are lowercased (RFC4343), other bytes are used as-is, like the C edition does.
+//
+// Both the producer and the consumer of a zone can thus derive the same key.
+func CombineKeys(inband string, outofband string, origindomain string) string {
+	origindomain = foldASCII(unfqdn(origindomain))
+
+	kdf := blake3.NewDeriveKey(KeyCombinationContext)
+
+	for _, part := range []string{origindomain, inband, outofband} {
+		var l [4]byte
+
+		binary.BigEndian.PutUint32(l[:], uint32(len(part)))
+
+		kdf.Write(l[:])
+		kdf.WriteString(part)
+	}
+
+	return hex.EncodeToString(kdf.Sum(nil))
+}
+
+// foldASCII returns s with the ASCII letters lowercased, other bytes are left as-is
+func foldASCII(s string) string {
+	b := []byte(s)
+
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+
+	return string(b)
+}
+
+// NewFromKeys creates a new HashedRPZ with the key derived by CombineKeys
+// from the in-band key, the out-of-band key and the origin of the zone.
+func NewFromKeys(inband string, outofband string, origindomain string, opts ...Option) HashedRPZ {
+	return New(CombineKeys(inband, outofband, origindomain), opts...)
+}
@@ -0,0 +1,61 @@
+package hashedrpz
+
+// Test vectors for the key combination
+
+import (
+	"testing"
+)
+
+type cktest struct {
+	InBand    string
+	OutOfBand string
+	Origin    string
+	Key       string
+}
+
+// cktests are the test vectors for CombineKeys
+var cktests = []cktest{
+	{"0KjULoiv d2VFuNPc", "RVabpOq3 eN6bmK0Z 2gwjCgDf fU2HVN5A", "rpz.example.net", "8285b410a50d2100bf87028e3099b37e18368703016d55c9278ff16a66cd4400"},
+	{"0KjULoiv d2VFuNPc", "RVabpOq3 eN6bmK0Z 2gwjCgDf fU2HVN5A", "RPZ.Example.NET.", "8285b410a50d2100bf87028e3099b37e18368703016d55c9278ff16a66cd4400"},
+	{"", "RVabpOq3 eN6bmK0Z 2gwjCgDf fU2HVN5A", "rpz.example.net", "c156a07777b5698e66ab772e0edaa537309c4e2aa144be2effd1a31dcb7599a7"},
+	{"0KjULoiv d2VFuNPcRVabpOq3", " eN6bmK0Z 2gwjCgDf fU2HVN5A", "rpz.example.net", "07cdaa9fd395829202d885f4d2122b97fc380d48a5d6927facc4d76bf3f40672"},
+
+	// Only ASCII is lowercased, other bytes (including invalid UTF-8) are used as-is
+	{"0KjULoiv d2VFuNPc", "RVabpOq3 eN6bmK0Z 2gwjCgDf fU2HVN5A", "RPZ.EX\u00c4MPLE.NET.", "c4ea85fa7afef823522accbbe1df7db617c7796c2e91f0efe7998ecd6df2974c"},
+	{"0KjULoiv d2VFuNPc", "RVabpOq3 eN6bmK0Z 2gwjCgDf fU2HVN5A", "rpz.ex\u00c4mple.net", "c4ea85fa7afef823522accbbe1df7db617c7796c2e91f0efe7998ecd6df2974c"},
+	{"0KjULoiv d2VFuNPc", "RVabpOq3 eN6bmK0Z 2gwjCgDf fU2HVN5A", "rpz.ex\u00e4mple.net", "e6c70973a2f6be9057fc5e36c32ce6c22ea11085f09557f07d617be5b8827800"},
+	{"0KjULoiv d2VFuNPc", "RVabpOq3 eN6bmK0Z 2gwjCgDf fU2HVN5A", "rpz.\xff.net", "aa67d285ceee3054337112a92d28bc1012d0064181f071362f5d53c67df3743f"},
+	{"0KjULoiv d2VFuNPc", "RVabpOq3 eN6bmK0Z 2gwjCgDf fU2HVN5A", "rpz.\ufffd.net", "d54c50f4d0da279240661fa13e5fda03d0bfe1eae39de7e8058d9af25b95965b"},
+}
+
+// TestCombineKeys checks the test vectors of CombineKeys
+func TestCombineKeys(t *testing.T) {
+	for _, tt := range cktests {
+		k := CombineKeys(tt.InBand, tt.OutOfBand, tt.Origin)
+		if k != tt.Key {
+			t.Errorf("Expected key %q for %q/%q/%q, got %q", tt.Key, tt.InBand, tt.OutOfBand, tt.Origin, k)
+		}
+	}
+
+	return
+}
+
+// TestNewFromKeys checks that NewFromKeys hashes with the combined key
+func TestNewFromKeys(t *testing.T) {
+	h := NewFromKeys(cktests[0].InBand, cktests[0].OutOfBand, cktests[0].Origin)
+
+	exp := map[string]string{
+		"com":             "j7ovnv0",
+		"www.example.com": "aek2i7g.gubiuije6oavs.j7ovnv0",
+		"*.example.net":   "*.qutg4a48atddc.ifke4v0",
+	}
+
+	for in, out := range exp {
+		o, err := h.Hash(in, origindomain, NoCallback)
+		if err != nil || o != out {
+			t.Errorf("Expected %q for %q, got %q (%v)", out, in, o, err)
+		}
+	}
+
+	return
+}
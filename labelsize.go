@@ -0,0 +1,153 @@
+package hashedrpz
+
+// Label size policies, determining the digest length used for a label.
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxLabelSize is the largest digest size (in bytes) that a LabelSizePolicy can select.
+const MaxLabelSize = 32
+
+// ErrInvalidLabelSizePolicy is returned when a LabelSizePolicy has sizes out of range or unordered tiers.
+var ErrInvalidLabelSizePolicy = errors.New("Invalid Label Size Policy")
+
+// SizeTier selects the digest size for labels shorter than Below
+type SizeTier struct {
+	// Below is the label length (exclusive) upto which this tier applies
+	Below int
+
+	// Size is the digest size in bytes for labels in this tier
+	Size int
+}
+
+// LabelSizePolicy determines the size of the digest that is used for a label
+// based on the length of that label (thus ```www``` for ```www.example.com```).
+//
+// Longer digests are more collision resistant, while fewer distinct
+// sizes leak less about the length of the labels; a FixedLabelSize
+// does not disclose anything about the label length at all.
+//
+// Tiers are checked in order, the first tier where the label length is
+// below the tier's Below determines the size, when no tier applies Size is used.
+type LabelSizePolicy struct {
+	// Tiers are the thresholds, in increasing order of Below
+	Tiers []SizeTier
+
+	// Size is the digest size in bytes for labels that are not covered by a tier
+	Size int
+}
+
+// TieredLabelSize is the default policy: labels shorter than 4 get a 4 byte digest,
+// shorter than 8 an 8 byte digest and all others a 16 byte digest.
+var TieredLabelSize = LabelSizePolicy{
+	Tiers: []SizeTier{
+		{Below: 4, Size: 4},
+		{Below: 8, Size: 8},
+	},
+	Size: 16,
+}
+
+// FixedLabelSize returns a policy that uses the same digest size for every label.
+func FixedLabelSize(size int) LabelSizePolicy {
+	return LabelSizePolicy{Size: size}
+}
+
+// Validate checks that all sizes are between 1 and MaxLabelSize and that the tiers are in increasing order.
+func (p LabelSizePolicy) Validate() error {
+	if p.Size < 1 || p.Size > MaxLabelSize {
+		return fmt.Errorf("%w: size %d not between 1 and %d", ErrInvalidLabelSizePolicy, p.Size, MaxLabelSize)
+	}
+
+	below := 0
+
+	for _, t := range p.Tiers {
+		if t.Below <= below {
+			return fmt.Errorf("%w: tier below %d not in increasing order", ErrInvalidLabelSizePolicy, t.Below)
+		}
+
+		if t.Size < 1 || t.Size > MaxLabelSize {
+			return fmt.Errorf("%w: tier size %d not between 1 and %d", ErrInvalidLabelSizePolicy, t.Size, MaxLabelSize)
+		}
+
+		below = t.Below
+	}
+
+	return nil
+}
+
+// DigestSize returns the digest size in bytes for a label of the given length.
+func (p *LabelSizePolicy) DigestSize(labellen int) int {
+	for _, t := range p.Tiers {
+		if labellen < t.Below {
+			return t.Size
+		}
+	}
+
+	return p.Size
+}
+
+// String returns the policy as a comma separated list of ```below:size``` tiers
+// followed by the size, thus TieredLabelSize is ```4:4,8:8,16``` and FixedLabelSize(16) is ```16```.
+func (p LabelSizePolicy) String() string {
+	var b strings.Builder
+
+	for _, t := range p.Tiers {
+		fmt.Fprintf(&b, "%d:%d,", t.Below, t.Size)
+	}
+
+	b.WriteString(strconv.Itoa(p.Size))
+
+	return b.String()
+}
+
+// ParseLabelSizePolicy parses the string form of a policy as returned by String.
+func ParseLabelSizePolicy(s string) (p LabelSizePolicy, err error) {
+	parts := strings.Split(s, ",")
+
+	for _, part := range parts[:len(parts)-1] {
+		var t SizeTier
+
+		bs := strings.SplitN(part, ":", 2)
+		if len(bs) != 2 {
+			err = fmt.Errorf("%w: invalid tier %q", ErrInvalidLabelSizePolicy, part)
+			return
+		}
+
+		t.Below, err = strconv.Atoi(bs[0])
+		if err == nil {
+			t.Size, err = strconv.Atoi(bs[1])
+		}
+
+		if err != nil {
+			err = fmt.Errorf("%w: invalid tier %q", ErrInvalidLabelSizePolicy, part)
+			return
+		}
+
+		p.Tiers = append(p.Tiers, t)
+	}
+
+	p.Size, err = strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		err = fmt.Errorf("%w: invalid size %q", ErrInvalidLabelSizePolicy, parts[len(parts)-1])
+		return
+	}
+
+	err = p.Validate()
+
+	return
+}
+
+// sizes returns all the digest sizes the policy can select
+func (p LabelSizePolicy) sizes() (sizes []int) {
+	sizes = append(sizes, p.Size)
+
+	for _, t := range p.Tiers {
+		sizes = append(sizes, t.Size)
+	}
+
+	return
+}
@@ -0,0 +1,98 @@
+package hashedrpz
+
+// Test vectors for the built-in label size policies, the C edition uses the same vectors.
+
+import (
+	"errors"
+	"testing"
+)
+
+// customLabelSize is a custom policy used for the test vectors
+var customLabelSize = LabelSizePolicy{
+	Tiers: []SizeTier{
+		{Below: 3, Size: 6},
+		{Below: 10, Size: 10},
+	},
+	Size: 20,
+}
+
+type lstest struct {
+	Name   string
+	Policy LabelSizePolicy
+	Input  string
+	Output string
+}
+
+// lstests are the test vectors per policy (TieredLabelSize is covered by tests)
+var lstests = []lstest{
+	{"fixed4", FixedLabelSize(4), "com", "8r4m02g"},
+	{"fixed4", FixedLabelSize(4), "www.example.com", "qtr7pq8.slhf50g.8r4m02g"},
+	{"fixed4", FixedLabelSize(4), "longerlabel.example.net", "n10m898.kj8qsm0.1qpnbgg"},
+	{"fixed4", FixedLabelSize(4), "*.example.net", "*.kj8qsm0.1qpnbgg"},
+	{"fixed16", FixedLabelSize(16), "com", "8r4m02nd4cvnat530nvkkt641s"},
+	{"fixed16", FixedLabelSize(16), "www.example.com", "qtr7pqf522v0k5rmg0e2l71flk.slhf50h8dgst0t6a2juct0plr0.8r4m02nd4cvnat530nvkkt641s"},
+	{"fixed16", FixedLabelSize(16), "longerlabel.example.net", "n10m898sngepm1u6t1h4hjkqhc.kj8qsm2gn1o43mndcdnf6n0ba8.1qpnbgntspv360nm1obolb5cag"},
+	{"fixed16", FixedLabelSize(16), "*.example.net", "*.kj8qsm2gn1o43mndcdnf6n0ba8.1qpnbgntspv360nm1obolb5cag"},
+	{"fixed32", FixedLabelSize(32), "com", "8r4m02nd4cvnat530nvkkt641t65cb0nhc4ukk82jaj7tbagbqv0"},
+	{"fixed32", FixedLabelSize(32), "example.com", "slhf50h8dgst0t6a2juct0plr37uo26241jesjj6onpngamggs20.8r4m02nd4cvnat530nvkkt641t65cb0nhc4ukk82jaj7tbagbqv0"},
+	{"custom", customLabelSize, "com", "8r4m02nd4cvnat53"},
+	{"custom", customLabelSize, "www.example.com", "qtr7pqf522v0k5rm.slhf50h8dgst0t6a.8r4m02nd4cvnat53"},
+	{"custom", customLabelSize, "longerlabel.example.net", "n10m898sngepm1u6t1h4hjkqhclqtqkv.kj8qsm2gn1o43mnd.1qpnbgntspv360nm"},
+	{"custom", customLabelSize, "*.example.net", "*.kj8qsm2gn1o43mnd.1qpnbgntspv360nm"},
+}
+
+// TestLabelSizePolicy checks the test vectors of the label size policies
+func TestLabelSizePolicy(t *testing.T) {
+	for _, tt := range lstests {
+		t.Run(tt.Name+"/"+tt.Input, func(t *testing.T) {
+			h := New(testkey, WithLabelSizePolicy(tt.Policy))
+
+			o, err := h.Hash(tt.Input, origindomain, NoCallback)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if o != tt.Output {
+				t.Errorf("Expected output %q but got: %q", tt.Output, o)
+				return
+			}
+		})
+	}
+
+	return
+}
+
+// TestLabelSizePolicyValidate checks that invalid policies are rejected
+func TestLabelSizePolicyValidate(t *testing.T) {
+	valid := []LabelSizePolicy{TieredLabelSize, FixedLabelSize(1), FixedLabelSize(MaxLabelSize), customLabelSize}
+
+	for _, p := range valid {
+		if err := p.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got: %s", p, err)
+		}
+	}
+
+	invalid := []LabelSizePolicy{
+		FixedLabelSize(0),
+		FixedLabelSize(MaxLabelSize + 1),
+		{Tiers: []SizeTier{{Below: 8, Size: 8}, {Below: 4, Size: 4}}, Size: 16},
+		{Tiers: []SizeTier{{Below: 4, Size: 0}}, Size: 16},
+	}
+
+	for _, p := range invalid {
+		if err := p.Validate(); !errors.Is(err, ErrInvalidLabelSizePolicy) {
+			t.Errorf("Expected %+v to be invalid, got: %v", p, err)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected New to panic for an invalid policy")
+		}
+	}()
+
+	New(testkey, WithLabelSizePolicy(FixedLabelSize(0)))
+
+	return
+}
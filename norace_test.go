@@ -0,0 +1,8 @@
+//go:build !race
+
+package hashedrpz
+
+// The race detector is disabled.
+
+// raceEnabled is true when the tests run with the race detector
+const raceEnabled = false
@@ -0,0 +1,130 @@
+package hashedrpz
+
+// Hashing a left hand side for multiple origins at once.
+
+import (
+	"errors"
+)
+
+// OriginResult is the result of HashForOrigins for a single origin
+type OriginResult struct {
+	// Origin is the origindomain this result is for
+	Origin string
+
+	// Output is what Hash returns for this origin, partial with ErrTooLong
+	Output string
+
+	// Err is what Hash returns for this origin
+	Err error
+}
+
+// Wildcard returns the result as HashWildcard returns it for this origin,
+// thus too long names are encoded as a wildcard inside the part that fitted.
+func (r OriginResult) Wildcard() (final string, iswildcard bool, err error) {
+	final, err = r.Output, r.Err
+
+	if errors.Is(err, ErrTooLong) {
+		iswildcard = true
+		final = "*." + final
+		err = nil
+	}
+
+	return
+}
+
+// labelRecord records where a label (or the wildcard) was put in h.final and what part of the
+// left hand side (h.name[lhs:end]) it is for, thus the result for another origin can be derived.
+type labelRecord struct {
+	start int
+	lhs   int
+	end   int
+}
+
+// HashForOrigins hashes the lefthandside for each of the origins, results[i] is for origins[i]
+// and is the same as what Hash returns for that origin (see OriginResult.Wildcard for HashWildcard).
+//
+// The labels are only hashed once, the origins only differ in how many labels fit (see ErrTooLong),
+// which is decided per origin. With domain separation (see Params.DomainSeparation) the labels
+// depend on the origin, thus they are then hashed for every origin.
+func (h *HashedRPZ) HashForOrigins(lefthandside string, origins []string) (results []OriginResult) {
+	results = make([]OriginResult, len(origins))
+
+	hs := h.get()
+	defer h.put(hs)
+
+	// The origin with the most room, thus the shortest one, the others get a part of its result
+	best := -1
+
+	for i, origin := range origins {
+		results[i].Origin = origin
+
+		if origin == "" || origin[0] == '.' {
+			results[i].Err = ErrInvalidOriginDomain
+			continue
+		}
+
+		if hs.separate {
+			start, err := hs.hash([]byte(lefthandside), []byte(origin), nil)
+			results[i].Output, results[i].Err = string(hs.final[start:]), hs.hashError(err)
+
+			continue
+		}
+
+		if best < 0 || wireLen([]byte(origin)) < wireLen([]byte(origins[best])) {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		return
+	}
+
+	hs.record = true
+
+	start, err := hs.hash([]byte(lefthandside), []byte(origins[best]), nil)
+	err = hs.hashError(err)
+
+	for i, origin := range origins {
+		if results[i].Err == ErrInvalidOriginDomain {
+			continue
+		}
+
+		ostart, oerr := hs.fitOrigin([]byte(origin), start, err)
+		results[i].Output, results[i].Err = string(hs.final[ostart:]), oerr
+	}
+
+	return
+}
+
+// fitOrigin derives the result for the origin from the recorded labels, it makes
+// the same decisions as hash, thus returns where the result starts in h.final.
+//
+// hstart and herr are the result of hash for the recorded labels; other errors than
+// ErrTooLong (e.g. ErrEmptySublabel) are returned when the origin fits all recorded labels,
+// as with a longer origin hashing stops with ErrTooLong before reaching that label.
+func (h *hasher) fitOrigin(origindomain []byte, hstart int, herr error) (start int, err error) {
+	maxdomainlen := maxWireNameLen - 1 - wireLen(origindomain)
+
+	start = len(h.final)
+	fit := start
+
+	for _, r := range h.records {
+		if len(h.final)-r.start > maxdomainlen {
+			start = fit
+			err = newHashError(ErrTooLong, h.input, h.name, r.lhs, r.end)
+			return
+		}
+
+		start = r.start
+
+		if len(h.final)-start+2 <= maxdomainlen {
+			fit = start
+		}
+	}
+
+	if herr != nil && !errors.Is(herr, ErrTooLong) {
+		start, err = hstart, herr
+	}
+
+	return
+}
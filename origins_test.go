@@ -0,0 +1,68 @@
+package hashedrpz
+
+// Tests for hashing for multiple origins
+
+import (
+	"strings"
+	"testing"
+)
+
+// testOrigins are origins of various lengths, thus the too long decisions differ
+var testOrigins = []string{
+	origindomain,
+	"RPZ.example.org.",
+	"",
+	".leading.example.net",
+	strings.Repeat("o.", 60) + "example.net",
+	strings.Repeat(strings.Repeat("x", 63)+".", 3) + strings.Repeat("y", 30) + ".example.net",
+	"x",
+}
+
+// TestHashForOrigins checks that the results are the same as Hash and HashWildcard per origin
+func TestHashForOrigins(t *testing.T) {
+	for _, p := range []Params{ParamsV1(), ParamsV3()} {
+		h := New(testkey, WithParams(p))
+
+		for _, tt := range tests {
+			results := h.HashForOrigins(tt.Input, testOrigins)
+
+			if len(results) != len(testOrigins) {
+				t.Fatalf("Expected %d results, got %d", len(testOrigins), len(results))
+			}
+
+			for i, r := range results {
+				exp, experr := h.Hash(tt.Input, testOrigins[i], NoCallback)
+
+				if r.Origin != testOrigins[i] || r.Output != exp || !sameError(r.Err, experr) {
+					t.Errorf("Version %d: expected %q (%v) for %q in %q but got: %q (%v)", p.Version, exp, experr, tt.Input, testOrigins[i], r.Output, r.Err)
+				}
+
+				expw, expwildcard, experr := h.HashWildcard(tt.Input, testOrigins[i], NoCallback)
+
+				w, wildcard, err := r.Wildcard()
+				if w != expw || wildcard != expwildcard || !sameError(err, experr) {
+					t.Errorf("Version %d: expected wildcard %q (%t, %v) for %q in %q but got: %q (%t, %v)", p.Version, expw, expwildcard, experr, tt.Input, testOrigins[i], w, wildcard, err)
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// TestHashForOriginsTruncation checks that a name can fit in one origin and not in another
+func TestHashForOriginsTruncation(t *testing.T) {
+	h := New(testkey)
+
+	results := h.HashForOrigins("jeroen.massar.ch", []string{origindomain, testOrigins[5]})
+
+	if results[0].Err != nil || results[0].Output != "jb5d0q8rg1i18.pm7ke6phus6js.44uhkl0" {
+		t.Errorf("Unexpected result %q (%v)", results[0].Output, results[0].Err)
+	}
+
+	if w, wildcard, err := results[1].Wildcard(); err != nil || !wildcard || w != "*.44uhkl0" {
+		t.Errorf("Expected a wildcard, got %q (%t, %v)", w, wildcard, err)
+	}
+
+	return
+}
@@ -0,0 +1,8 @@
+//go:build race
+
+package hashedrpz
+
+// The race detector is enabled, sync.Pool then randomly drops items, thus allocates.
+
+// raceEnabled is true when the tests run with the race detector
+const raceEnabled = true
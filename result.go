@@ -0,0 +1,183 @@
+package hashedrpz
+
+// Structured results, exposing every hashed label and the part of the lefthandside it covers.
+
+import (
+	"errors"
+	"strings"
+)
+
+// Label is a single hashed label of a Result
+type Label struct {
+	// Hash is the hashed label (base32hex-lowercase) or "*" for a wildcard
+	Hash string
+
+	// Suffix is the plaintext part of the lefthandside that was hashed for this label
+	// e.g. for the ```example``` label of ```www.example.com``` it is ```example.com```.
+	// It is in the canonical form that was hashed, use EscapeName to render it fully escaped.
+	Suffix string
+}
+
+// Result is the structured variant of the output of Hash
+type Result struct {
+	// Labels are the hashed labels in ownername order, thus the leftmost label
+	// first and the hash of the TLD last.
+	Labels []Label
+
+	// Wildcard indicates that a wildcard was emitted as the leftmost label
+	Wildcard bool
+
+	// TruncatedAt is the depth (the TLD being 1) of the last label that was hashed
+	// before ErrTooLong stopped the hashing, it is 0 when no truncation happened.
+	TruncatedAt int
+
+	// Unhashed is the part of the lefthandside that was not hashed due to truncation
+	Unhashed string
+
+	// Origin is the origindomain as passed to HashResult
+	Origin string
+
+	// OriginLength is the length of the origin in wire format (including the root label)
+	OriginLength int
+
+	// Length is the length of the full ownername (the hashed labels and the origin) in wire format,
+	// which can be at most 255 octets (RFC1035), see Remaining.
+	Length int
+}
+
+// HashResult is Hash, but returns a Result detailing every label.
+//
+// The errors are the same as for Hash, Result contains the labels hashed upto the error,
+// thus with ErrTooLong the caller can still inspect what was hashed.
+func (h *HashedRPZ) HashResult(lefthandside string, origindomain string) (r Result, err error) {
+	hs := h.get()
+	defer h.put(hs)
+
+	// Record the name as it was hashed
+	hs.record = true
+
+	start, err := hs.hash([]byte(lefthandside), []byte(origindomain), nil)
+	final, err := string(hs.final[start:]), hs.hashError(err)
+
+	// The suffixes are those of the name as it was hashed, thus in canonical form,
+	// lowercased with case folding and with IDNA in A-label form
+	if hs.name != nil {
+		lefthandside = string(hs.name)
+	}
+
+	r = newResult(lefthandside, origindomain, final)
+
+	if errors.Is(err, ErrTooLong) {
+		r.TruncatedAt = len(r.Labels)
+
+		// Everything in front of the last hashed suffix
+		if len(r.Labels) > 0 {
+			lhs := unfqdn(lefthandside)
+			r.Unhashed = lhs[:len(lhs)-len(r.Labels[0].Suffix)]
+
+			// Without the separator
+			if r.Unhashed != "" {
+				r.Unhashed = r.Unhashed[:len(r.Unhashed)-1]
+			}
+		}
+	}
+
+	return
+}
+
+// unfqdn removes the optional trailing dot of a name, an escaped dot is kept
+func unfqdn(name string) string {
+	if strings.HasSuffix(name, ".") && !isEscaped([]byte(name), len(name)-1) {
+		return name[:len(name)-1]
+	}
+
+	return name
+}
+
+// newResult constructs a Result from the output of Hash by pairing each
+// hashed label with the suffix of the lefthandside it was hashed from.
+func newResult(lefthandside string, origindomain string, final string) (r Result) {
+	r.Origin = origindomain
+	r.OriginLength = wireLen([]byte(origindomain))
+	r.Length = r.OriginLength
+
+	if final == "" {
+		return
+	}
+
+	hashes := strings.Split(final, ".")
+
+	// A wildcard alone results in "*."
+	if hashes[len(hashes)-1] == "" {
+		hashes = hashes[:len(hashes)-1]
+	}
+
+	r.Labels = make([]Label, len(hashes))
+
+	// Walk from the TLD to the left, just like Hash does
+	lhs := unfqdn(lefthandside)
+	suffix := len(lhs)
+
+	for i := len(hashes) - 1; i >= 0; i-- {
+		suffix = lastSeparator([]byte(lhs), suffix) + 1
+
+		r.Labels[i] = Label{Hash: hashes[i], Suffix: lhs[suffix:]}
+
+		// The hashes are base32hex or a wildcard, thus never escaped, plus the length octet
+		r.Length += len(hashes[i]) + 1
+
+		// Skip the separator
+		if suffix > 0 {
+			suffix--
+		}
+	}
+
+	r.Wildcard = r.Labels[0].Hash == "*"
+
+	return
+}
+
+// Relative renders the result relative to the origin, this is the same as Hash returns
+func (r Result) Relative() string {
+	hashes := make([]string, len(r.Labels))
+
+	for i, l := range r.Labels {
+		hashes[i] = l.Hash
+	}
+
+	s := strings.Join(hashes, ".")
+
+	// Hash returns "*." for a lone wildcard, keep that the same
+	if len(r.Labels) == 1 && r.Wildcard {
+		s += "."
+	}
+
+	return s
+}
+
+// String returns the result relative to the origin, see Relative
+func (r Result) String() string {
+	return r.Relative()
+}
+
+// FQDN renders the result fully qualified within the origin (thus ending in a '.')
+func (r Result) FQDN() string {
+	rel := strings.TrimSuffix(r.Relative(), ".")
+	origin := unfqdn(r.Origin)
+
+	if rel == "" {
+		return origin + "."
+	}
+
+	return rel + "." + origin + "."
+}
+
+// Remaining returns the number of octets the ownername can still grow before it exceeds 255 octets in wire format
+func (r Result) Remaining() int {
+	return maxWireNameLen - r.Length
+}
+
+// Depth returns the number of labels in the result
+func (r Result) Depth() int {
+	return len(r.Labels)
+}
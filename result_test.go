@@ -0,0 +1,192 @@
+package hashedrpz
+
+// Tests for the structured Result
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestHashResult checks that HashResult renders the same as Hash and covers the right suffixes
+func TestHashResult(t *testing.T) {
+	h := New(testkey)
+
+	for _, tt := range tests {
+		t.Run(tt.Input, func(t *testing.T) {
+			exp, experr := h.Hash(tt.Input, origindomain, NoCallback)
+
+			r, err := h.HashResult(tt.Input, origindomain)
+			if !sameError(err, experr) {
+				t.Errorf("Expected error %s but got: %s", experr, err)
+				return
+			}
+
+			if r.Relative() != exp {
+				t.Errorf("Expected output %q but got: %q", exp, r.Relative())
+				return
+			}
+
+			// Every label covers its own suffix, thus has one label more than the one to its right
+			for i, l := range r.Labels {
+				if i > 0 && r.Labels[i-1].Suffix[len(r.Labels[i-1].Suffix)-len(l.Suffix)-1:] != "."+l.Suffix {
+					t.Errorf("Label %d suffix %q is not a parent of %q", i, l.Suffix, r.Labels[i-1].Suffix)
+				}
+			}
+		})
+	}
+
+	return
+}
+
+// TestHashResultCaseFold checks that with case folding the suffixes are the lowercased ones that were hashed
+func TestHashResultCaseFold(t *testing.T) {
+	h := New(testkey, WithParams(ParamsV2()))
+
+	r, err := h.HashResult("WWW.Example.COM.", origindomain)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// The callback receives the suffixes as hashed
+	var exp []string
+	h.Hash("WWW.Example.COM.", origindomain, func(subdomain string, hash string) {
+		exp = append([]string{subdomain}, exp...)
+	})
+
+	if len(r.Labels) != len(exp) {
+		t.Fatalf("Expected %d labels but got: %d", len(exp), len(r.Labels))
+	}
+
+	for i, suffix := range []string{"www.example.com", "example.com", "com"} {
+		if r.Labels[i].Suffix != suffix || exp[i] != suffix {
+			t.Errorf("Expected suffix %q for label %d but got: %q (callback: %q)", suffix, i, r.Labels[i].Suffix, exp[i])
+		}
+	}
+
+	return
+}
+
+// TestHashResultLabels checks the individual labels and the rendering
+func TestHashResultLabels(t *testing.T) {
+	h := New(testkey)
+
+	r, err := h.HashResult("*.example.net.", origindomain)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	exp := []Label{
+		{"*", "*.example.net"},
+		{"kj8qsm2gn1o42", "example.net"},
+		{"1qpnbgg", "net"},
+	}
+
+	if len(r.Labels) != len(exp) {
+		t.Fatalf("Expected %d labels, got %d", len(exp), len(r.Labels))
+	}
+
+	for i := range exp {
+		if r.Labels[i] != exp[i] {
+			t.Errorf("Expected label %d to be %+v, got %+v", i, exp[i], r.Labels[i])
+		}
+	}
+
+	if !r.Wildcard || r.TruncatedAt != 0 || r.Depth() != 3 {
+		t.Errorf("Unexpected wildcard %t, truncation %d or depth %d", r.Wildcard, r.TruncatedAt, r.Depth())
+	}
+
+	if r.FQDN() != "*.kj8qsm2gn1o42.1qpnbgg.rpz.example.net." {
+		t.Errorf("Unexpected FQDN %q", r.FQDN())
+	}
+
+	return
+}
+
+// TestHashResultTruncated checks that the truncation depth and unhashed part are reported
+func TestHashResultTruncated(t *testing.T) {
+	h := New(testkey)
+
+	tt := tests[len(tests)-1]
+
+	r, err := h.HashResult(tt.Input, origindomain)
+	if !errors.Is(err, ErrTooLong) {
+		t.Fatalf("Expected error %s but got: %s", ErrTooLong, err)
+	}
+
+	if r.TruncatedAt != r.Depth() || r.TruncatedAt != 26 {
+		t.Errorf("Expected truncation at 26, got %d (depth %d)", r.TruncatedAt, r.Depth())
+	}
+
+	if r.Unhashed != "a.b.c" {
+		t.Errorf("Expected unhashed %q, got %q", "a.b.c", r.Unhashed)
+	}
+
+	if "*."+r.Relative() != tt.Output {
+		t.Errorf("Expected %q, got %q", tt.Output, "*."+r.Relative())
+	}
+
+	return
+}
+
+// TestHashResultLength checks the wire format lengths and that ErrTooLong is only returned when exceeding 255 octets
+func TestHashResultLength(t *testing.T) {
+	h := New(testkey)
+
+	r, err := h.HashResult("*.example.net.", origindomain)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// *.kj8qsm2gn1o42.1qpnbgg.rpz.example.net.
+	if r.OriginLength != 17 || r.Length != 41 || r.Remaining() != 214 {
+		t.Errorf("Unexpected origin length %d, length %d or remaining %d", r.OriginLength, r.Length, r.Remaining())
+	}
+
+	lhs := "example.net"
+
+	for i := 0; i < 40; i++ {
+		lhs = "a." + lhs
+
+		r, err := h.HashResult(lhs, origindomain)
+
+		// The same with a trailing dot on the origin
+		fr, ferr := h.HashResult(lhs, origindomain+".")
+		if !sameError(ferr, err) || fr.Relative() != r.Relative() || fr.Length != r.Length {
+			t.Fatalf("%s: origin with trailing dot resulted in %q (%d, %v) instead of %q (%d, %v)", lhs, fr.Relative(), fr.Length, ferr, r.Relative(), r.Length, err)
+		}
+
+		// The length of the full name, using a one letter origin as that never hits the limit here
+		full, ferr := h.HashResult(lhs, "x")
+		if ferr != nil {
+			t.Fatalf("%s: unexpected error with a short origin: %s", lhs, ferr)
+		}
+
+		fulllen := full.Length - full.OriginLength + r.OriginLength
+
+		switch {
+		case err == nil:
+			if r.Length != fulllen || r.Length != wireLen([]byte(r.FQDN())) || r.Length > maxWireNameLen {
+				t.Fatalf("%s: unexpected length %d (full %d, remaining %d)", lhs, r.Length, fulllen, r.Remaining())
+			}
+
+		case errors.Is(err, ErrTooLong):
+			if fulllen <= maxWireNameLen {
+				t.Fatalf("%s: too long, but would be %d octets", lhs, fulllen)
+			}
+
+			// The labels that were returned have to fit with a wildcard
+			if r.Remaining() < 2 {
+				t.Fatalf("%s: truncated result does not fit with a wildcard, %d remaining", lhs, r.Remaining())
+			}
+
+			return
+
+		default:
+			t.Fatalf("%s: unexpected error: %s", lhs, err)
+		}
+	}
+
+	t.Errorf("Expected ErrTooLong")
+
+	return
+}
@@ -0,0 +1,153 @@
+package hashedrpz
+
+// Deterministic key schedule, deriving a key per time epoch from a master secret.
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/zeebo/blake3"
+)
+
+// KeyScheduleContext is the BLAKE3 DeriveKey context string used by KeySchedule.
+const KeyScheduleContext = "github.com/massar/hashedrpz 2026-10-16 epoch key schedule v1"
+
+// ErrInvalidKeySchedule is returned when the period or overlap of a KeySchedule are invalid
+var ErrInvalidKeySchedule = errors.New("Invalid Key Schedule (period must be a positive number of seconds, overlap less than the period)")
+
+// KeySchedule derives a key per epoch from a master secret, thus producers and
+// resolvers that share the master secret rotate their keys in lock-step without
+// having to exchange a new key every period.
+//
+// Epochs are counted in periods since the Unix epoch (1970-01-01 00:00:00 UTC),
+// thus with a period of 24 hours every epoch starts at midnight UTC.
+//
+// The master secret can for instance be the result of CombineKeys, binding the schedule to a zone.
+type KeySchedule struct {
+	master  string
+	period  time.Duration
+	overlap time.Duration
+}
+
+// EpochKey is the key of a single epoch of a KeySchedule
+type EpochKey struct {
+	// Epoch is the number of the epoch
+	Epoch int64
+
+	// ID identifies the key, it is the epoch number as a string
+	ID string
+
+	// Key is the key string for New
+	Key string
+
+	// NotBefore is the start of the epoch minus the overlap
+	NotBefore time.Time
+
+	// NotAfter is the end of the epoch plus the overlap
+	NotAfter time.Time
+}
+
+// NewKeySchedule creates a new KeySchedule deriving a key every period (e.g. time.Hour or 24*time.Hour).
+//
+// The key of an epoch is also valid overlap before the epoch starts and overlap after
+// the epoch ended, which allows for clock skew and propagation of the zone.
+//
+// Returns ErrInvalidKeySchedule when the period is not a positive number of whole seconds
+// or the overlap is negative or not less than the period.
+func NewKeySchedule(master string, period time.Duration, overlap time.Duration) (*KeySchedule, error) {
+	if period < time.Second || period%time.Second != 0 || overlap < 0 || overlap >= period {
+		return nil, ErrInvalidKeySchedule
+	}
+
+	return &KeySchedule{master: master, period: period, overlap: overlap}, nil
+}
+
+// Epoch returns the number of the epoch that time t falls in
+func (s *KeySchedule) Epoch(t time.Time) int64 {
+	secs := int64(s.period / time.Second)
+	unix := t.Unix()
+
+	// Floor division, thus times before 1970 also land in the right epoch
+	epoch := unix / secs
+	if unix%secs < 0 {
+		epoch--
+	}
+
+	return epoch
+}
+
+// EpochStart returns the time at which the epoch starts
+func (s *KeySchedule) EpochStart(epoch int64) time.Time {
+	return time.Unix(epoch*int64(s.period/time.Second), 0).UTC()
+}
+
+// Key returns the key of the given epoch, the key string for New is derived as:
+//
+//	material = lp(master) || uint64-be(period in seconds) || int64-be(epoch)
+//	key      = lowercase-hex(BLAKE3-DeriveKey(KeyScheduleContext, material)[:32])
+//
+// Where lp(x) is the length of x as a 32 bit big-endian integer followed by x itself.
+func (s *KeySchedule) Key(epoch int64) EpochKey {
+	var b [8]byte
+
+	kdf := blake3.NewDeriveKey(KeyScheduleContext)
+
+	binary.BigEndian.PutUint32(b[:4], uint32(len(s.master)))
+	kdf.Write(b[:4])
+	kdf.WriteString(s.master)
+
+	binary.BigEndian.PutUint64(b[:], uint64(s.period/time.Second))
+	kdf.Write(b[:])
+
+	binary.BigEndian.PutUint64(b[:], uint64(epoch))
+	kdf.Write(b[:])
+
+	start := s.EpochStart(epoch)
+
+	return EpochKey{
+		Epoch:     epoch,
+		ID:        strconv.FormatInt(epoch, 10),
+		Key:       hex.EncodeToString(kdf.Sum(nil)),
+		NotBefore: start.Add(-s.overlap),
+		NotAfter:  start.Add(s.period + s.overlap),
+	}
+}
+
+// KeyAt returns the key of the epoch that time t falls in
+func (s *KeySchedule) KeyAt(t time.Time) EpochKey {
+	return s.Key(s.Epoch(t))
+}
+
+// KeysAt returns the keys valid at time t, thus the key of the current epoch and
+// when t is within the overlap, the key of the previous or next epoch.
+//
+// The keys are ordered by epoch, thus the previous key first.
+func (s *KeySchedule) KeysAt(t time.Time) (keys []EpochKey) {
+	epoch := s.Epoch(t)
+
+	for e := epoch - 1; e <= epoch+1; e++ {
+		k := s.Key(e)
+
+		if !t.Before(k.NotBefore) && t.Before(k.NotAfter) {
+			keys = append(keys, k)
+		}
+	}
+
+	return
+}
+
+// Keyring returns a Keyring holding the keys valid at time t,
+// each HashedRPZ is created by New with the given options, thus these have to be valid.
+func (s *KeySchedule) Keyring(t time.Time, opts ...Option) *Keyring {
+	k := NewKeyring()
+
+	for _, ek := range s.KeysAt(t) {
+		// IDs are unique per epoch, thus this can not fail
+		k.Add(ek.ID, New(ek.Key, opts...), ek.NotBefore, ek.NotAfter)
+	}
+
+	return k
+}
@@ -0,0 +1,111 @@
+package hashedrpz
+
+// Test vectors for the KeySchedule
+
+import (
+	"testing"
+	"time"
+)
+
+const testmaster = "master: 0KjULoiv d2VFuNPc RVabpOq3"
+
+// TestKeySchedule checks the epochs and the test vectors of the derived keys
+func TestKeySchedule(t *testing.T) {
+	hourly, err := NewKeySchedule(testmaster, time.Hour, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create hourly schedule: %s", err)
+	}
+
+	daily, err := NewKeySchedule(testmaster, 24*time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create daily schedule: %s", err)
+	}
+
+	at := time.Date(2020, 11, 1, 12, 30, 0, 0, time.UTC)
+
+	checks := []struct {
+		S     *KeySchedule
+		At    time.Time
+		Epoch int64
+		Key   string
+	}{
+		{hourly, at, 445620, "010494029b3b0c097ab2ae3345b5b67f0c2fbf56c30768c2810294a4cf460e81"},
+		{hourly, at.Add(29*time.Minute + 59*time.Second), 445620, "010494029b3b0c097ab2ae3345b5b67f0c2fbf56c30768c2810294a4cf460e81"},
+		{daily, at, 18567, "33823093218772a4b8b8795611937b4db08f9c897feaadfdd2f34dfb56af5f61"},
+		{hourly, time.Unix(-1, 0), -1, "d2c3feb72c9f67232ab7487bba92f8f1bcef4261b42b73900cba1ffa514db567"},
+	}
+
+	for _, c := range checks {
+		k := c.S.KeyAt(c.At)
+		if k.Epoch != c.Epoch || k.Key != c.Key {
+			t.Errorf("At %s expected epoch %d key %q, got epoch %d key %q", c.At, c.Epoch, c.Key, k.Epoch, k.Key)
+		}
+	}
+
+	h := New(hourly.KeyAt(at).Key)
+	if o, err := h.Hash("www.example.com", origindomain, NoCallback); err != nil || o != "kpd109g.i4jovo5e2d2mc.5hio5eo" {
+		t.Errorf("Expected %q, got %q (%v)", "kpd109g.i4jovo5e2d2mc.5hio5eo", o, err)
+	}
+
+	for _, p := range []struct{ Period, Overlap time.Duration }{
+		{0, 0},
+		{time.Hour + time.Millisecond, 0},
+		{time.Hour, -time.Minute},
+		{time.Hour, time.Hour},
+	} {
+		if _, err := NewKeySchedule(testmaster, p.Period, p.Overlap); err != ErrInvalidKeySchedule {
+			t.Errorf("Expected error %s for period %s overlap %s, got: %v", ErrInvalidKeySchedule, p.Period, p.Overlap, err)
+		}
+	}
+
+	return
+}
+
+// TestKeyScheduleKeysAt checks which epoch keys are valid around an epoch boundary
+func TestKeyScheduleKeysAt(t *testing.T) {
+	s, _ := NewKeySchedule(testmaster, time.Hour, 5*time.Minute)
+	base := time.Date(2020, 11, 1, 12, 0, 0, 0, time.UTC)
+
+	checks := []struct {
+		At     time.Time
+		Epochs []int64
+	}{
+		{base.Add(-10 * time.Minute), []int64{445619}},
+		{base.Add(-5 * time.Minute), []int64{445619, 445620}},
+		{base, []int64{445619, 445620}},
+		{base.Add(4*time.Minute + 59*time.Second), []int64{445619, 445620}},
+		{base.Add(5 * time.Minute), []int64{445620}},
+		{base.Add(30 * time.Minute), []int64{445620}},
+		{base.Add(55 * time.Minute), []int64{445620, 445621}},
+	}
+
+	for _, c := range checks {
+		keys := s.KeysAt(c.At)
+
+		var epochs []int64
+		for _, k := range keys {
+			epochs = append(epochs, k.Epoch)
+		}
+
+		if len(epochs) != len(c.Epochs) {
+			t.Errorf("At %s expected epochs %v, got %v", c.At, c.Epochs, epochs)
+			continue
+		}
+
+		for i := range epochs {
+			if epochs[i] != c.Epochs[i] {
+				t.Errorf("At %s expected epochs %v, got %v", c.At, c.Epochs, epochs)
+				break
+			}
+		}
+
+		// The Keyring holds the same keys, the current one is the newest
+		k := s.Keyring(c.At)
+		cur, ok := k.Current(c.At)
+		if !ok || cur.ID != keys[len(keys)-1].ID || len(k.Valid(c.At)) != len(keys) {
+			t.Errorf("At %s expected keyring with %v, got %v", c.At, c.Epochs, keyIDs(k.Valid(c.At)))
+		}
+	}
+
+	return
+}
@@ -0,0 +1,287 @@
+package hashedrpz
+
+// Scheme parameters, identifying which variant of the HashedRPZ algorithm produced a zone.
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SchemeV1 is the original HashedRPZ scheme:
+// BLAKE3 with the key derived from the key string (blake3.NewDeriveKey),
+// hashing each label including its parents as-is and encoding
+// the digest as base32hex-lowercase (RFC4648) without padding.
+const SchemeV1 = 1
+
+// SchemeV2 is SchemeV1 with case folding enabled by default:
+// the left hand side is lowercased (ASCII only, as per RFC4343) before hashing,
+// thus ```WWW.Example.COM``` results in the same output as ```www.example.com```.
+const SchemeV2 = 2
+
+// SchemeV3 is SchemeV2 with domain separation enabled by default: the origin and the depth
+// of the label are hashed in front of every label (see Params.DomainSeparation),
+// thus the same name hashes differently in every zone and at every depth.
+const SchemeV3 = 3
+
+// schemePrefix is the prefix of the version in the canonical string form
+const schemePrefix = "HRPZ"
+
+// ErrUnknownVersion is returned when the scheme version is not supported
+var ErrUnknownVersion = errors.New("Unknown HashedRPZ scheme version")
+
+// ErrInvalidParams is returned when the scheme parameters can not be parsed
+var ErrInvalidParams = errors.New("Invalid HashedRPZ scheme parameters")
+
+// Params are the parameters of the HashedRPZ scheme, the producer and consumer
+// of a zone need to use the same parameters (and key) to get the same output.
+//
+// The canonical string form (see String) can be published alongside the zone,
+// e.g. in the ```_rpzhashkey``` TXT record, so that consumers can detect a mismatch.
+type Params struct {
+	// Version is the scheme version, see SchemeV1, SchemeV2 and SchemeV3
+	Version int
+
+	// LabelSize is the policy for the digest size of each label
+	LabelSize LabelSizePolicy
+
+	// CaseFold lowercases the ASCII letters of the left hand side before hashing,
+	// DNS names are case-insensitive (RFC4343) and resolvers might randomise the
+	// case of query names (0x20 randomisation), thus without it these would not match.
+	CaseFold bool
+
+	// IDNA selects the conversion of Unicode domain names to A-labels, see IDNAMode
+	IDNA IDNAMode
+
+	// Hash selects the keyed hash backend, see HashAlgorithm (HashBLAKE3 by default)
+	Hash HashAlgorithm
+
+	// Encoding selects how the digests are encoded as labels, see LabelEncoding (EncodingBase32Hex by default)
+	Encoding LabelEncoding
+
+	// DomainSeparation binds the origin and the depth of the label into the hashed input,
+	// thus entries can not be correlated between zones sharing a key. Every label is hashed as:
+	//
+	//	lp(origin) || depth || suffix
+	//
+	// Where lp(origin) is the length of the origin (lowercased, without trailing dot) as a 32 bit
+	// big-endian integer followed by the origin itself, depth is a single byte with the number of
+	// labels of the suffix (the TLD being 1) and the suffix is what is hashed without domain separation.
+	DomainSeparation bool
+}
+
+// ParamsV1 returns the parameters of SchemeV1 with its defaults.
+func ParamsV1() Params {
+	return Params{
+		Version:   SchemeV1,
+		LabelSize: TieredLabelSize,
+	}
+}
+
+// ParamsV2 returns the parameters of SchemeV2 with its defaults.
+func ParamsV2() Params {
+	return Params{
+		Version:   SchemeV2,
+		LabelSize: TieredLabelSize,
+		CaseFold:  true,
+	}
+}
+
+// ParamsV3 returns the parameters of SchemeV3 with its defaults.
+func ParamsV3() Params {
+	return Params{
+		Version:          SchemeV3,
+		LabelSize:        TieredLabelSize,
+		CaseFold:         true,
+		DomainSeparation: true,
+	}
+}
+
+// versionParams returns the parameters with the defaults for the given version
+func versionParams(version int) (p Params, err error) {
+	switch version {
+	case SchemeV1:
+		p = ParamsV1()
+
+	case SchemeV2:
+		p = ParamsV2()
+
+	case SchemeV3:
+		p = ParamsV3()
+
+	default:
+		err = fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return
+}
+
+// DefaultParams returns the parameters used by New when none are provided.
+func DefaultParams() Params {
+	return ParamsV1()
+}
+
+// Validate checks that the version is known and the parameters are valid for it.
+func (p Params) Validate() error {
+	if _, err := versionParams(p.Version); err != nil {
+		return err
+	}
+
+	if err := p.IDNA.Validate(); err != nil {
+		return err
+	}
+
+	if err := p.Hash.Validate(); err != nil {
+		return err
+	}
+
+	if err := p.Encoding.Validate(); err != nil {
+		return err
+	}
+
+	if err := p.LabelSize.Validate(); err != nil {
+		return err
+	}
+
+	// The hashed labels have to fit in a DNS label
+	for _, size := range p.LabelSize.sizes() {
+		if l := p.Encoding.EncodedLen(size); l > maxWireLabelLen {
+			return fmt.Errorf("%w: size %d encodes to %d characters with %s, more than %d", ErrInvalidLabelSizePolicy, size, l, p.Encoding, maxWireLabelLen)
+		}
+	}
+
+	return nil
+}
+
+// String returns the canonical string form of the parameters, for example:
+//
+//	v=HRPZ1; ls=4:4,8:8,16
+//
+// The tags are always in the same order, thus the string can be compared.
+// The ```cf``` (case folding), ```idna```, ```h``` (hash algorithm), ```enc``` (label encoding)
+// and ```ds``` (domain separation) tags are only included when they differ from the default of the version.
+func (p Params) String() string {
+	s := fmt.Sprintf("v=%s%d; ls=%s", schemePrefix, p.Version, p.LabelSize)
+
+	d, _ := versionParams(p.Version)
+
+	if d.CaseFold != p.CaseFold {
+		s += "; cf=" + boolTag(p.CaseFold)
+	}
+
+	if d.IDNA != p.IDNA {
+		s += "; idna=" + p.IDNA.String()
+	}
+
+	if d.Hash != p.Hash {
+		s += "; h=" + p.Hash.String()
+	}
+
+	if d.Encoding != p.Encoding {
+		s += "; enc=" + p.Encoding.String()
+	}
+
+	if d.DomainSeparation != p.DomainSeparation {
+		s += "; ds=" + boolTag(p.DomainSeparation)
+	}
+
+	return s
+}
+
+// boolTag returns the value of a boolean tag
+func boolTag(b bool) string {
+	if b {
+		return "1"
+	}
+
+	return "0"
+}
+
+// parseBoolTag parses the value of a boolean tag
+func parseBoolTag(tag string, v string) (b bool, err error) {
+	switch v {
+	case "1":
+		b = true
+
+	case "0":
+		b = false
+
+	default:
+		err = fmt.Errorf("%w: invalid value %q for %s", ErrInvalidParams, v, tag)
+	}
+
+	return
+}
+
+// ParseParams parses the canonical string form of the parameters as returned by String.
+//
+// Tags that are not present get the default of the version, unknown versions
+// and unknown tags are rejected, as they could change the output.
+func ParseParams(s string) (p Params, err error) {
+	tags := strings.Split(s, ";")
+
+	// The version comes first as it determines the defaults
+	v := strings.TrimSpace(tags[0])
+	if !strings.HasPrefix(v, "v="+schemePrefix) {
+		err = fmt.Errorf("%w: missing version in %q", ErrInvalidParams, s)
+		return
+	}
+
+	p.Version, err = strconv.Atoi(v[len("v="+schemePrefix):])
+	if err != nil {
+		err = fmt.Errorf("%w: invalid version %q", ErrInvalidParams, v)
+		return
+	}
+
+	p, err = versionParams(p.Version)
+	if err != nil {
+		return
+	}
+
+	for _, tag := range tags[1:] {
+		tag = strings.TrimSpace(tag)
+
+		// Allow a trailing ';'
+		if tag == "" {
+			continue
+		}
+
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			err = fmt.Errorf("%w: invalid tag %q", ErrInvalidParams, tag)
+			return
+		}
+
+		switch kv[0] {
+		case "ls":
+			p.LabelSize, err = ParseLabelSizePolicy(kv[1])
+
+		case "cf":
+			p.CaseFold, err = parseBoolTag(kv[0], kv[1])
+
+		case "idna":
+			p.IDNA, err = ParseIDNAMode(kv[1])
+
+		case "h":
+			p.Hash, err = ParseHashAlgorithm(kv[1])
+
+		case "enc":
+			p.Encoding, err = ParseLabelEncoding(kv[1])
+
+		case "ds":
+			p.DomainSeparation, err = parseBoolTag(kv[0], kv[1])
+
+		default:
+			err = fmt.Errorf("%w: unknown tag %q", ErrInvalidParams, kv[0])
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	err = p.Validate()
+
+	return
+}
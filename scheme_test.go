@@ -0,0 +1,200 @@
+package hashedrpz
+
+// Tests for the scheme parameters
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestParamsString checks the canonical string form and that it parses back
+func TestParamsString(t *testing.T) {
+	p := DefaultParams()
+
+	if p.String() != "v=HRPZ1; ls=4:4,8:8,16" {
+		t.Errorf("Unexpected canonical form %q", p.String())
+	}
+
+	for _, pol := range []LabelSizePolicy{TieredLabelSize, FixedLabelSize(16), customLabelSize} {
+		for _, p := range []Params{ParamsV1(), ParamsV2()} {
+			for _, fold := range []bool{false, true} {
+				p.LabelSize = pol
+				p.CaseFold = fold
+
+				pp, err := ParseParams(p.String())
+				if err != nil {
+					t.Errorf("Failed to parse %q: %s", p.String(), err)
+					continue
+				}
+
+				if pp.String() != p.String() || pp.CaseFold != fold {
+					t.Errorf("Expected %q after parsing, got %q", p.String(), pp.String())
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// TestParseParams checks the parser, including that unknown versions are rejected
+func TestParseParams(t *testing.T) {
+	valid := map[string]string{
+		"v=HRPZ1":                   "v=HRPZ1; ls=4:4,8:8,16",
+		"v=HRPZ1;":                  "v=HRPZ1; ls=4:4,8:8,16",
+		" v=HRPZ1 ;  ls=16 ":        "v=HRPZ1; ls=16",
+		"v=HRPZ1; ls=3:6,10:10,20;": "v=HRPZ1; ls=3:6,10:10,20",
+		"v=HRPZ1; cf=1":             "v=HRPZ1; ls=4:4,8:8,16; cf=1",
+		"v=HRPZ1; cf=0":             "v=HRPZ1; ls=4:4,8:8,16",
+		"v=HRPZ2":                   "v=HRPZ2; ls=4:4,8:8,16",
+		"v=HRPZ2; cf=1; ls=16":      "v=HRPZ2; ls=16",
+		"v=HRPZ2; cf=0":             "v=HRPZ2; ls=4:4,8:8,16; cf=0",
+		"v=HRPZ1; idna=map":         "v=HRPZ1; ls=4:4,8:8,16; idna=map",
+		"v=HRPZ2; idna=strict":      "v=HRPZ2; ls=4:4,8:8,16; idna=strict",
+		"v=HRPZ1; idna=none":        "v=HRPZ1; ls=4:4,8:8,16",
+		"v=HRPZ1; h=hmac-sha256":    "v=HRPZ1; ls=4:4,8:8,16; h=hmac-sha256",
+		"v=HRPZ2; h=blake3":         "v=HRPZ2; ls=4:4,8:8,16",
+		"v=HRPZ3":                   "v=HRPZ3; ls=4:4,8:8,16",
+		"v=HRPZ3; ds=0":             "v=HRPZ3; ls=4:4,8:8,16; ds=0",
+		"v=HRPZ2; ds=1":             "v=HRPZ2; ls=4:4,8:8,16; ds=1",
+		"v=HRPZ1; enc=base36":       "v=HRPZ1; ls=4:4,8:8,16; enc=base36",
+		"v=HRPZ1; enc=hex; ls=31":   "v=HRPZ1; ls=31; enc=hex",
+	}
+
+	for s, exp := range valid {
+		p, err := ParseParams(s)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %s", s, err)
+			continue
+		}
+
+		if p.String() != exp {
+			t.Errorf("Expected %q for %q, got %q", exp, s, p.String())
+		}
+	}
+
+	invalid := map[string]error{
+		"":                        ErrInvalidParams,
+		"ls=16":                   ErrInvalidParams,
+		"v=HRPZ":                  ErrInvalidParams,
+		"v=HRPZx":                 ErrInvalidParams,
+		"v=HRPZ0":                 ErrUnknownVersion,
+		"v=HRPZ99; ls=16":         ErrUnknownVersion,
+		"v=HRPZ1; xx=1":           ErrInvalidParams,
+		"v=HRPZ1; ls":             ErrInvalidParams,
+		"v=HRPZ1; ls=0":           ErrInvalidLabelSizePolicy,
+		"v=HRPZ1; ls=8:8,4:4,1":   ErrInvalidLabelSizePolicy,
+		"v=HRPZ1; ls=4-4,16":      ErrInvalidLabelSizePolicy,
+		"v=HRPZ2; cf=yes":         ErrInvalidParams,
+		"v=HRPZ1; idna=uts46":     ErrInvalidIDNAMode,
+		"v=HRPZ1; h=md5":          ErrInvalidHashAlgorithm,
+		"v=HRPZ3; ds=2":           ErrInvalidParams,
+		"v=HRPZ1; enc=base64":     ErrInvalidEncoding,
+		"v=HRPZ1; enc=hex; ls=32": ErrInvalidLabelSizePolicy,
+		"v=HRPZ4":                 ErrUnknownVersion,
+	}
+
+	for s, experr := range invalid {
+		_, err := ParseParams(s)
+		if !errors.Is(err, experr) {
+			t.Errorf("Expected error %s for %q, got: %v", experr, s, err)
+		}
+	}
+
+	return
+}
+
+// TestWithParams checks that the parameters are used and reported by the HashedRPZ
+func TestWithParams(t *testing.T) {
+	// Version 1 is frozen, thus the default tests need to produce the same output
+	p, err := ParseParams("v=HRPZ1")
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+
+	h := New(testkey, WithParams(p))
+
+	if h.Params().String() != p.String() {
+		t.Errorf("Expected params %q, got %q", p.String(), h.Params().String())
+	}
+
+	for _, tt := range tests {
+		o, err := h.Hash(tt.Input, origindomain, NoCallback)
+		if !errors.Is(err, tt.Error) || (err == nil && o != tt.Output) {
+			t.Errorf("Expected %q (%v) for %q but got: %q (%v)", tt.Output, tt.Error, tt.Input, o, err)
+		}
+	}
+
+	// A label size policy from the parameters
+	p, err = ParseParams("v=HRPZ1; ls=16")
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+
+	h = New(testkey, WithParams(p))
+
+	o, err := h.Hash("www.example.com", origindomain, NoCallback)
+	if err != nil || o != "qtr7pqf522v0k5rmg0e2l71flk.slhf50h8dgst0t6a2juct0plr0.8r4m02nd4cvnat530nvkkt641s" {
+		t.Errorf("Unexpected output %q (%v)", o, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected New to panic for an unknown version")
+		}
+	}()
+
+	New(testkey, WithParams(Params{Version: 99, LabelSize: TieredLabelSize}))
+
+	return
+}
+
+// migrationTests are the outputs of the scheme versions for the same input (using testkey),
+// version 3 binds the origin (lowercased, without trailing dot) and thus differs per zone.
+var migrationTests = []struct {
+	Version int
+	Origin  string
+	Input   string
+	Output  string
+}{
+	{SchemeV1, "rpz.example.net", "www.example.com", "qtr7pq8.slhf50h8dgst0.8r4m02g"},
+	{SchemeV1, "rpz.example.org", "www.example.com", "qtr7pq8.slhf50h8dgst0.8r4m02g"},
+	{SchemeV2, "rpz.example.net", "WWW.Example.com", "qtr7pq8.slhf50h8dgst0.8r4m02g"},
+	{SchemeV2, "rpz.example.org", "www.example.com", "qtr7pq8.slhf50h8dgst0.8r4m02g"},
+	{SchemeV3, "rpz.example.net", "www.example.com", "3m4ch8o.onab5lbg004jq.58f0neo"},
+	{SchemeV3, "RPZ.Example.NET.", "WWW.example.com", "3m4ch8o.onab5lbg004jq.58f0neo"},
+	{SchemeV3, "rpz.example.org", "www.example.com", "sd9rhpg.vi9ejms6isquq.cafqj9o"},
+	{SchemeV3, "rpz.example.net", "*.example.net", "*.7h42qbn7sp7jk.ls0tma8"},
+	{SchemeV3, "rpz.example.org", "*.example.net", "*.dkjbhac7hmlto.8sjboug"},
+}
+
+// TestDomainSeparation checks the migration test vectors
+func TestDomainSeparation(t *testing.T) {
+	for _, tt := range migrationTests {
+		p, err := versionParams(tt.Version)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		h := New(testkey, WithParams(p))
+
+		o, err := h.Hash(tt.Input, tt.Origin, NoCallback)
+		if err != nil || o != tt.Output {
+			t.Errorf("Version %d: expected %q for %q in %q but got: %q (%v)", tt.Version, tt.Output, tt.Input, tt.Origin, o, err)
+		}
+	}
+
+	// The construction, reproducible with any HMAC-SHA256 implementation:
+	// HMAC(key, "\x00\x00\x00\x0f" || "rpz.example.net" || depth || suffix) truncated to the label size
+	p := ParamsV3()
+	p.Hash = HashHMACSHA256
+
+	h := New(testkey, WithParams(p))
+
+	o, err := h.Hash("www.example.com", "RPZ.example.net.", NoCallback)
+	if exp := "bm81jn8.35v84kr1fbmdk.daeur70"; err != nil || o != exp {
+		t.Errorf("Expected %q but got: %q (%v)", exp, o, err)
+	}
+
+	return
+}
@@ -0,0 +1,230 @@
+package hashedrpz
+
+// Policy triggers other than the query name, e.g. response IP (rpz-ip) triggers.
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+)
+
+// The labels ending the names of the triggers other than the query name, these are kept as-is when hashing
+const (
+	// TriggerIP is the label of response IP triggers (```32.4.3.2.1.rpz-ip```)
+	TriggerIP = "rpz-ip"
+
+	// TriggerNSDName is the label of nameserver name triggers (```ns.example.com.rpz-nsdname```)
+	TriggerNSDName = "rpz-nsdname"
+
+	// TriggerNSIP is the label of nameserver IP triggers (```32.4.3.2.1.rpz-nsip```)
+	TriggerNSIP = "rpz-nsip"
+)
+
+// ErrInvalidPrefix is returned for a prefix that can not be a trigger
+var ErrInvalidPrefix = errors.New("Invalid IP prefix")
+
+// IPTrigger returns the left hand side of the response IP trigger for the prefix, as used by RPZ:
+// the prefix length followed by the address in reverse order (```10.0.0.0/8``` is ```8.0.0.0.10.rpz-ip```).
+//
+// IPv6 addresses are the 16 bit words in hexadecimal without leading zeros, the longest run of
+// two or more zero words (the first one when there are multiple) is replaced by ```zz```,
+// thus ```2001:db8::/32``` is ```32.zz.db8.2001.rpz-ip```.
+// IPv4-mapped IPv6 prefixes of at least 96 bits are the IPv4 prefix, as that is how resolvers see them.
+//
+// An error wrapping ErrInvalidPrefix is returned for an invalid prefix, a prefix of length 0
+// (that would block every address, also ```::ffff:0:0/96```) and a prefix with bits set beyond its length.
+func IPTrigger(prefix netip.Prefix) (lefthandside string, err error) {
+	name, err := appendIPTrigger(nil, prefix, TriggerIP)
+	lefthandside = string(name)
+
+	return
+}
+
+// HashIP hashes the response IP trigger of the prefix (see IPTrigger), the labels of the
+// address are hashed like Hash does, while the ```rpz-ip``` label is kept as-is, thus resolvers
+// still recognise the trigger: ```32.4.3.2.1.rpz-ip``` becomes ```<hash>.<hash>.<hash>.<hash>.<hash>.rpz-ip```.
+//
+// The hashed labels include the ```rpz-ip``` label, thus they never match those of a query name trigger.
+//
+// Resolvers hash the prefixes of a response address that policies can match, e.g. for 1.2.3.4 the
+// prefixes /32 down to /1, using netip.Prefix.Masked. AppendHashIP does so without allocations.
+//
+// ErrTooLong is returned when the trigger does not fit in the origindomain, unlike
+// a name the trigger can not be a wildcard, thus the partial result is not usable.
+func (h *HashedRPZ) HashIP(prefix netip.Prefix, origindomain string) (final string, err error) {
+	hs := h.get()
+	defer h.put(hs)
+
+	out, err := hs.appendHashTrigger(nil, prefix, []byte(origindomain), TriggerIP)
+	final, err = string(out), hs.hashError(err)
+
+	return
+}
+
+// AppendHashIP is the allocation-free variant of HashIP, it appends the hashed trigger to dst.
+func (h *HashedRPZ) AppendHashIP(dst []byte, prefix netip.Prefix, origindomain []byte) ([]byte, error) {
+	hs := h.get()
+	defer h.put(hs)
+
+	return hs.appendHashTrigger(dst, prefix, origindomain, TriggerIP)
+}
+
+// HashNSIP hashes the nameserver IP trigger of the prefix, the same as HashIP
+// does, but ending in the ```rpz-nsip``` label (```<hash>.<hash>.<hash>.<hash>.<hash>.rpz-nsip```).
+func (h *HashedRPZ) HashNSIP(prefix netip.Prefix, origindomain string) (final string, err error) {
+	hs := h.get()
+	defer h.put(hs)
+
+	out, err := hs.appendHashTrigger(nil, prefix, []byte(origindomain), TriggerNSIP)
+	final, err = string(out), hs.hashError(err)
+
+	return
+}
+
+// AppendHashNSIP is the allocation-free variant of HashNSIP, it appends the hashed trigger to dst.
+func (h *HashedRPZ) AppendHashNSIP(dst []byte, prefix netip.Prefix, origindomain []byte) ([]byte, error) {
+	hs := h.get()
+	defer h.put(hs)
+
+	return hs.appendHashTrigger(dst, prefix, origindomain, TriggerNSIP)
+}
+
+// HashNSDName hashes the name of a nameserver as a nameserver name trigger, which is the result of
+// Hash followed by the ```rpz-nsdname``` label (```<hash>.<hash>.<hash>.rpz-nsdname``` for ```ns.example.com```).
+//
+// The name is hashed with Hash as if the origindomain is ```rpz-nsdname.<origindomain>```, thus the
+// length is limited correctly and with domain separation the labels differ from those of a query name.
+// Everything else is the same as Hash, including wildcards (```*.example.com``` blocks all nameservers in that domain)
+// and ErrTooLong, the partial result then ends in the trigger label and can be prefixed with a wildcard.
+// The callback is passed the hashed labels without the trigger label.
+func (h *HashedRPZ) HashNSDName(lefthandside string, origindomain string, callback HashCallback) (final string, err error) {
+	hs := h.get()
+	defer h.put(hs)
+
+	out, err := hs.appendHashName(nil, []byte(lefthandside), []byte(origindomain), wrapCallback(callback), TriggerNSDName)
+	final, err = string(out), hs.hashError(err)
+
+	return
+}
+
+// AppendHashNSDName is the allocation-free variant of HashNSDName, it appends the hashed trigger to dst.
+func (h *HashedRPZ) AppendHashNSDName(dst []byte, lefthandside []byte, origindomain []byte) ([]byte, error) {
+	hs := h.get()
+	defer h.put(hs)
+
+	return hs.appendHashName(dst, lefthandside, origindomain, nil, TriggerNSDName)
+}
+
+// appendHashName appends the hashed lefthandside followed by the trigger label to dst,
+// the trigger label is put in front of the origindomain for hashing (see HashNSDName).
+func (hs *hasher) appendHashName(dst []byte, lefthandside []byte, origindomain []byte, callback hashCallback, trigger string) ([]byte, error) {
+	// An invalid origin stays invalid, hash rejects it
+	hs.triggername = hs.triggername[:0]
+	if len(origindomain) != 0 && origindomain[0] != '.' {
+		hs.triggername = append(append(hs.triggername, trigger...), '.')
+	}
+
+	hs.triggername = append(hs.triggername, origindomain...)
+
+	start, err := hs.hash(lefthandside, hs.triggername, callback)
+	if start == len(hs.final) {
+		// Nothing was hashed
+		return dst, err
+	}
+
+	dst = append(dst, hs.final[start:]...)
+	dst = append(dst, '.')
+	dst = append(dst, trigger...)
+
+	return dst, err
+}
+
+// appendHashTrigger appends the hashed trigger of the prefix with the trigger label to dst
+func (hs *hasher) appendHashTrigger(dst []byte, prefix netip.Prefix, origindomain []byte, trigger string) ([]byte, error) {
+	var err error
+
+	hs.triggername, err = appendIPTrigger(hs.triggername[:0], prefix, trigger)
+	if err != nil {
+		return dst, err
+	}
+
+	hs.trigger = append(hs.trigger[:0], trigger...)
+
+	start, err := hs.hash(hs.triggername, origindomain, nil)
+
+	return append(dst, hs.final[start:]...), err
+}
+
+// appendIPTrigger appends the left hand side of the trigger for the prefix to dst (see IPTrigger)
+func appendIPTrigger(dst []byte, prefix netip.Prefix, trigger string) ([]byte, error) {
+	if !prefix.IsValid() {
+		return dst, fmt.Errorf("%w: %s", ErrInvalidPrefix, prefix)
+	}
+
+	if prefix.Masked() != prefix {
+		return dst, fmt.Errorf("%w: %s has bits set beyond the prefix length, did you mean %s?", ErrInvalidPrefix, prefix, prefix.Masked())
+	}
+
+	addr, bits := prefix.Addr(), prefix.Bits()
+
+	if addr.Is4In6() && bits >= 96 {
+		addr, bits = addr.Unmap(), bits-96
+	}
+
+	if bits == 0 {
+		return dst, fmt.Errorf("%w: %s would match every address", ErrInvalidPrefix, prefix)
+	}
+
+	dst = strconv.AppendInt(dst, int64(bits), 10)
+
+	if addr.Is4() {
+		a := addr.As4()
+
+		for i := len(a) - 1; i >= 0; i-- {
+			dst = append(dst, '.')
+			dst = strconv.AppendUint(dst, uint64(a[i]), 10)
+		}
+	} else {
+		a := addr.As16()
+
+		var words [8]uint16
+		for i := range words {
+			words[i] = uint16(a[i*2])<<8 | uint16(a[i*2+1])
+		}
+
+		// The longest run of zero words, the first one when there are multiple
+		zstart, zlen := -1, 1
+		for i := 0; i < len(words); {
+			j := i
+			for j < len(words) && words[j] == 0 {
+				j++
+			}
+
+			if j-i > zlen {
+				zstart, zlen = i, j-i
+			}
+
+			i = j + 1
+		}
+
+		for i := len(words) - 1; i >= 0; i-- {
+			if i >= zstart && i < zstart+zlen {
+				// The run is replaced by a single zz
+				if i == zstart {
+					dst = append(dst, ".zz"...)
+				}
+
+				continue
+			}
+
+			dst = append(dst, '.')
+			dst = strconv.AppendUint(dst, uint64(words[i]), 16)
+		}
+	}
+
+	dst = append(dst, '.')
+	dst = append(dst, trigger...)
+
+	return dst, nil
+}
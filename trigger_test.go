@@ -0,0 +1,249 @@
+package hashedrpz
+
+// Tests for the policy triggers
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// TestIPTrigger checks the reversed prefix form, including the zz compression
+func TestIPTrigger(t *testing.T) {
+	tests := []struct {
+		Prefix string
+		Output string
+	}{
+		{"1.2.3.4/32", "32.4.3.2.1.rpz-ip"},
+		{"10.0.0.0/8", "8.0.0.0.10.rpz-ip"},
+		{"128.0.0.0/1", "1.0.0.0.128.rpz-ip"},
+		{"::ffff:1.2.3.4/128", "32.4.3.2.1.rpz-ip"},
+		{"64:ff9b::/96", "96.zz.ff9b.64.rpz-ip"},
+		{"2001:db8::/32", "32.zz.db8.2001.rpz-ip"},
+		{"2001:2::3/128", "128.3.zz.2.2001.rpz-ip"},
+		{"::1/128", "128.1.zz.rpz-ip"},
+		{"8000::/1", "1.zz.8000.rpz-ip"},
+		{"2001:db8:1:2:3:4:5:6/128", "128.6.5.4.3.2.1.db8.2001.rpz-ip"},
+
+		// A single zero word is not compressed
+		{"2001:db8:0:1:1:1:1:1/128", "128.1.1.1.1.1.0.db8.2001.rpz-ip"},
+
+		// The longest run
+		{"2001:0:0:1:0:0:0:1/128", "128.1.zz.1.0.0.2001.rpz-ip"},
+
+		// The first run of equal length
+		{"2001:0:0:1:0:0:1:1/128", "128.1.1.0.0.1.zz.2001.rpz-ip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Prefix, func(t *testing.T) {
+			out, err := IPTrigger(netip.MustParsePrefix(tt.Prefix))
+			if err != nil || out != tt.Output {
+				t.Errorf("Expected %q but got: %q (%v)", tt.Output, out, err)
+			}
+		})
+	}
+
+	return
+}
+
+// TestIPTriggerInvalid checks that prefixes that can not be a trigger are rejected
+func TestIPTriggerInvalid(t *testing.T) {
+	tests := []netip.Prefix{
+		{},
+		netip.MustParsePrefix("0.0.0.0/0"),
+		netip.MustParsePrefix("::/0"),
+		netip.MustParsePrefix("::ffff:0:0/96"),
+		netip.MustParsePrefix("1.2.3.4/8"),
+		netip.MustParsePrefix("2001:db8::1/64"),
+	}
+
+	h := New(testkey)
+
+	for _, prefix := range tests {
+		if _, err := IPTrigger(prefix); !errors.Is(err, ErrInvalidPrefix) {
+			t.Errorf("Expected %s for %s but got: %v", ErrInvalidPrefix, prefix, err)
+		}
+
+		if _, err := h.HashIP(prefix, origindomain); !errors.Is(err, ErrInvalidPrefix) {
+			t.Errorf("Expected %s for %s but got: %v", ErrInvalidPrefix, prefix, err)
+		}
+	}
+
+	return
+}
+
+// TestHashIP checks that the address labels are hashed like Hash does and the trigger label is kept
+func TestHashIP(t *testing.T) {
+	h := New(testkey)
+
+	out, err := h.HashIP(netip.MustParsePrefix("1.2.3.4/32"), origindomain)
+
+	exp := "vbjhrlo.fgqtm08.pd276v0.9nkihsg.5pj8nrg.rpz-ip"
+	if err != nil || out != exp {
+		t.Errorf("Expected %q but got: %q (%v)", exp, out, err)
+	}
+
+	for _, p := range []Params{ParamsV1(), ParamsV2(), ParamsV3()} {
+		h := New(testkey, WithParams(p))
+
+		for _, prefix := range []string{"1.2.3.4/32", "10.0.0.0/8", "2001:db8::/32", "2001:db8:1:2:3:4:5:6/128"} {
+			lhs, err := IPTrigger(netip.MustParsePrefix(prefix))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			// Hash hashes the trigger label too, the other labels are the same
+			hashed, err := h.Hash(lhs, origindomain, NoCallback)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			exp := hashed[:strings.LastIndexByte(hashed, '.')+1] + TriggerIP
+
+			out, err := h.HashIP(netip.MustParsePrefix(prefix), origindomain)
+			if err != nil || out != exp {
+				t.Errorf("Version %d: expected %q for %s but got: %q (%v)", p.Version, exp, prefix, out, err)
+			}
+
+			app, err := h.AppendHashIP([]byte("x:"), netip.MustParsePrefix(prefix), []byte(origindomain))
+			if err != nil || string(app) != "x:"+exp {
+				t.Errorf("Version %d: expected %q for %s but got: %q (%v)", p.Version, "x:"+exp, prefix, app, err)
+			}
+		}
+	}
+
+	return
+}
+
+// TestHashIPTooLong checks that a trigger that does not fit the origin is rejected
+func TestHashIPTooLong(t *testing.T) {
+	h := New(testkey)
+
+	origin := strings.Repeat(strings.Repeat("x", 63)+".", 3) + strings.Repeat("y", 50) + ".net"
+
+	for _, prefix := range []string{"1.2.3.4/32", "2001:db8::/32"} {
+		if _, err := h.HashIP(netip.MustParsePrefix(prefix), origin); !errors.Is(err, ErrTooLong) {
+			t.Errorf("Expected %s for %s but got: %v", ErrTooLong, prefix, err)
+		}
+	}
+
+	// Not even the trigger label fits
+	origin = strings.Repeat(strings.Repeat("x", 63)+".", 3) + strings.Repeat("y", 57) + ".net"
+
+	if _, err := h.HashIP(netip.MustParsePrefix("1.2.3.4/32"), origin); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Expected %s but got: %v", ErrTooLong, err)
+	}
+
+	return
+}
+
+// BenchmarkHashIP measures hashing all the prefixes of an IPv6 address, as a resolver would
+func BenchmarkHashIP(b *testing.B) {
+	h := New(testkey)
+	addr := netip.MustParseAddr("2001:db8:1:2:3:4:5:6")
+	origin := []byte(origindomain)
+	out := make([]byte, 0, 256)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		for bits := 128; bits > 0; bits -= 16 {
+			prefix, _ := addr.Prefix(bits)
+			out, _ = h.AppendHashIP(out[:0], prefix, origin)
+		}
+	}
+
+	return
+}
+
+// TestHashNSIP checks that the nameserver IP triggers are hashed including their own trigger label
+func TestHashNSIP(t *testing.T) {
+	h := New(testkey)
+
+	for _, prefix := range []string{"1.2.3.4/32", "2001:db8::/32"} {
+		lhs, err := IPTrigger(netip.MustParsePrefix(prefix))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		lhs = strings.TrimSuffix(lhs, TriggerIP) + TriggerNSIP
+
+		hashed, err := h.Hash(lhs, origindomain, NoCallback)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		exp := hashed[:strings.LastIndexByte(hashed, '.')+1] + TriggerNSIP
+
+		out, err := h.HashNSIP(netip.MustParsePrefix(prefix), origindomain)
+		if err != nil || out != exp {
+			t.Errorf("Expected %q for %s but got: %q (%v)", exp, prefix, out, err)
+		}
+
+		app, err := h.AppendHashNSIP(nil, netip.MustParsePrefix(prefix), []byte(origindomain))
+		if err != nil || string(app) != exp {
+			t.Errorf("Expected %q for %s but got: %q (%v)", exp, prefix, app, err)
+		}
+
+		// Not the same as the response IP trigger
+		ip, _ := h.HashIP(netip.MustParsePrefix(prefix), origindomain)
+		if strings.TrimSuffix(ip, TriggerIP) == strings.TrimSuffix(out, TriggerNSIP) {
+			t.Errorf("Expected different labels for %s, got: %q and %q", prefix, ip, out)
+		}
+	}
+
+	return
+}
+
+// TestHashNSDName checks that nameserver names are hashed by Hash with the trigger label in front of the origin
+func TestHashNSDName(t *testing.T) {
+	h := New(testkey)
+
+	out, err := h.HashNSDName("www.example.com", origindomain, NoCallback)
+
+	// The labels are those of the query name, as the origin is not bound in version 1
+	exp := "qtr7pq8.slhf50h8dgst0.8r4m02g.rpz-nsdname"
+	if err != nil || out != exp {
+		t.Errorf("Expected %q but got: %q (%v)", exp, out, err)
+	}
+
+	names := []string{
+		"www.example.com",
+		"*.example.com",
+		"ns1.example.net.",
+		"",
+		"empty..sublabel.example.net",
+		tests[len(tests)-1].Input,
+	}
+
+	for _, p := range []Params{ParamsV1(), ParamsV3()} {
+		h := New(testkey, WithParams(p))
+
+		for _, lhs := range names {
+			hashed, experr := h.Hash(lhs, TriggerNSDName+"."+origindomain, NoCallback)
+
+			exp := ""
+			if hashed != "" {
+				exp = hashed + "." + TriggerNSDName
+			}
+
+			out, err := h.HashNSDName(lhs, origindomain, NoCallback)
+			if out != exp || !sameError(err, experr) {
+				t.Errorf("Version %d: expected %q (%v) for %q but got: %q (%v)", p.Version, exp, experr, lhs, out, err)
+			}
+
+			app, err := h.AppendHashNSDName([]byte("x:"), []byte(lhs), []byte(origindomain))
+			if string(app) != "x:"+exp || err != bareError(experr) {
+				t.Errorf("Version %d: expected %q (%v) for %q but got: %q (%v)", p.Version, "x:"+exp, experr, lhs, app, err)
+			}
+		}
+	}
+
+	if _, err := h.HashNSDName("www.example.com", ".leading.example.net", NoCallback); err != ErrInvalidOriginDomain {
+		t.Errorf("Expected %s but got: %v", ErrInvalidOriginDomain, err)
+	}
+
+	return
+}
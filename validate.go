@@ -0,0 +1,198 @@
+package hashedrpz
+
+// Strict validation of names before hashing, rejecting names that can not exist in the DNS.
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLabelTooLong is returned in strict mode when a label is longer than 63 octets
+var ErrLabelTooLong = errors.New("Label longer than 63 octets")
+
+// ErrNameTooLong is returned in strict mode when the name is longer than 255 octets (in wire format)
+var ErrNameTooLong = errors.New("Name longer than 255 octets")
+
+// ErrInvalidCharacter is returned in strict mode when a label contains a character not allowed by the CharRule
+var ErrInvalidCharacter = errors.New("Invalid character in label")
+
+// ErrInvalidHyphen is returned in strict mode when a label starts or ends with a hyphen (CharsLDH and CharsLDHUnderscore)
+var ErrInvalidHyphen = errors.New("Label starts or ends with a hyphen")
+
+// ErrInvalidCharRule is returned when parsing an unknown CharRule
+var ErrInvalidCharRule = errors.New("Invalid character rule")
+
+// ValidationError is returned in strict mode (see WithStrict) for a name that is not valid,
+// Err is one of ErrLabelTooLong, ErrNameTooLong, ErrInvalidCharacter or ErrInvalidHyphen.
+//
+// Use errors.Is to check for the specific violation or errors.As to get the details.
+type ValidationError struct {
+	// Err is the violation
+	Err error
+
+	// Label is the offending label (in canonical presentation form), empty for ErrNameTooLong
+	Label string
+
+	// Offset is the offset of the offending label in the left hand side (in canonical presentation form)
+	Offset int
+}
+
+// Error returns the violation and the offending label
+func (e *ValidationError) Error() string {
+	if e.Label == "" {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf("%s: %q", e.Err, e.Label)
+}
+
+// Unwrap returns the violation, thus errors.Is(err, ErrLabelTooLong) works
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// CharRule selects which characters are allowed in labels in strict mode
+type CharRule int
+
+const (
+	// CharsAny allows any character, thus only the lengths are checked
+	CharsAny CharRule = iota
+
+	// CharsLDH allows letters, digits and hyphens (RFC1123), a label can not start or end with a hyphen
+	CharsLDH
+
+	// CharsLDHUnderscore is CharsLDH but also allows underscores, as used by
+	// SRV (```_sip._tcp```), DKIM (```_domainkey```) and DMARC (```_dmarc```) names
+	CharsLDHUnderscore
+)
+
+// String returns the name of the rule
+func (r CharRule) String() string {
+	switch r {
+	case CharsAny:
+		return "any"
+
+	case CharsLDH:
+		return "ldh"
+
+	case CharsLDHUnderscore:
+		return "ldh-underscore"
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(r))
+}
+
+// ParseCharRule parses the name of a rule as returned by String
+func ParseCharRule(s string) (r CharRule, err error) {
+	switch s {
+	case "any":
+		r = CharsAny
+
+	case "ldh":
+		r = CharsLDH
+
+	case "ldh-underscore":
+		r = CharsLDHUnderscore
+
+	default:
+		err = fmt.Errorf("%w: %q", ErrInvalidCharRule, s)
+	}
+
+	return
+}
+
+// Validate checks that the rule is known
+func (r CharRule) Validate() error {
+	if r < CharsAny || r > CharsLDHUnderscore {
+		return fmt.Errorf("%w: %d", ErrInvalidCharRule, int(r))
+	}
+
+	return nil
+}
+
+// allowed returns true when the character is allowed in a label
+func (r CharRule) allowed(c byte) bool {
+	if r == CharsAny {
+		return true
+	}
+
+	if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || isDigit(c) || c == '-' {
+		return true
+	}
+
+	return r == CharsLDHUnderscore && c == '_'
+}
+
+// WithStrict enables strict validation: every label has to be at most 63 octets,
+// the name at most 255 octets (in wire format) and the characters have to be allowed
+// by the CharRule. A leading wildcard is always allowed.
+//
+// Names violating these get a *ValidationError instead of being hashed, this catches
+// names that can not exist in the DNS, e.g. garbage lines from broken feeds.
+func WithStrict(chars CharRule) Option {
+	return func(o *options) {
+		o.strict = true
+		o.chars = chars
+	}
+}
+
+// validateName checks the name in canonical form (without trailing dot) according to the CharRule.
+//
+// Empty labels are not checked here, hash reports these as ErrEmptySublabel.
+func validateName(name []byte, chars CharRule) error {
+	// The root label
+	wirelen := 1
+
+	for start := 0; start <= len(name); {
+		end := start
+		for end < len(name) && (name[end] != '.' || isEscaped(name, end)) {
+			end++
+		}
+
+		if err := validateLabel(name[start:end], start, chars); err != nil {
+			return err
+		}
+
+		wirelen += unescapedLen(name[start:end]) + 1
+
+		start = end + 1
+	}
+
+	if wirelen > maxWireNameLen {
+		return &ValidationError{Err: ErrNameTooLong}
+	}
+
+	return nil
+}
+
+// validateLabel checks a single label in canonical form, offset is used for the ValidationError
+func validateLabel(label []byte, offset int, chars CharRule) error {
+	n := unescapedLen(label)
+
+	// A wildcard can only be at the start, hash checks for that
+	if n == 0 || (len(label) == 1 && label[0] == '*') {
+		return nil
+	}
+
+	if n > maxWireLabelLen {
+		return &ValidationError{Err: ErrLabelTooLong, Label: string(label), Offset: offset}
+	}
+
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if c == '\\' {
+			i++
+			c = label[i]
+		}
+
+		if !chars.allowed(c) {
+			return &ValidationError{Err: ErrInvalidCharacter, Label: string(label), Offset: offset}
+		}
+	}
+
+	if chars != CharsAny && (label[0] == '-' || label[len(label)-1] == '-') {
+		return &ValidationError{Err: ErrInvalidHyphen, Label: string(label), Offset: offset}
+	}
+
+	return nil
+}
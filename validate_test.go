@@ -0,0 +1,104 @@
+package hashedrpz
+
+// Tests for the strict validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestHashStrict checks the violations per character rule
+func TestHashStrict(t *testing.T) {
+	label63 := strings.Repeat("a", 63)
+	name255 := strings.Repeat(label63+".", 3) + strings.Repeat("a", 61)
+
+	checks := []struct {
+		Input string
+		Rules map[CharRule]error
+	}{
+		{"www.example.com", map[CharRule]error{CharsAny: nil, CharsLDH: nil, CharsLDHUnderscore: nil}},
+		{"*.example.com", map[CharRule]error{CharsAny: nil, CharsLDH: nil, CharsLDHUnderscore: nil}},
+		{"xn--bcher-kva.example.", map[CharRule]error{CharsAny: nil, CharsLDH: nil, CharsLDHUnderscore: nil}},
+		{"_dmarc.example.com", map[CharRule]error{CharsAny: nil, CharsLDH: ErrInvalidCharacter, CharsLDHUnderscore: nil}},
+		{"_sip._tcp.example.com", map[CharRule]error{CharsAny: nil, CharsLDH: ErrInvalidCharacter, CharsLDHUnderscore: nil}},
+		{"a b.example.com", map[CharRule]error{CharsAny: nil, CharsLDH: ErrInvalidCharacter, CharsLDHUnderscore: ErrInvalidCharacter}},
+		{`a\.b.example.com`, map[CharRule]error{CharsAny: nil, CharsLDH: ErrInvalidCharacter, CharsLDHUnderscore: ErrInvalidCharacter}},
+		{"example.com\t", map[CharRule]error{CharsAny: nil, CharsLDH: ErrInvalidCharacter, CharsLDHUnderscore: ErrInvalidCharacter}},
+		{"-abc.example.com", map[CharRule]error{CharsAny: nil, CharsLDH: ErrInvalidHyphen, CharsLDHUnderscore: ErrInvalidHyphen}},
+		{"abc-.example.com", map[CharRule]error{CharsAny: nil, CharsLDH: ErrInvalidHyphen, CharsLDHUnderscore: ErrInvalidHyphen}},
+		{label63 + ".example.com", map[CharRule]error{CharsAny: nil, CharsLDH: nil, CharsLDHUnderscore: nil}},
+		{label63 + "a.example.com", map[CharRule]error{CharsAny: ErrLabelTooLong, CharsLDH: ErrLabelTooLong, CharsLDHUnderscore: ErrLabelTooLong}},
+		// An escape counts as a single octet
+		{strings.Repeat(`\097`, 63) + ".example.com", map[CharRule]error{CharsAny: nil, CharsLDH: nil, CharsLDHUnderscore: nil}},
+		{name255, map[CharRule]error{CharsAny: nil, CharsLDH: nil, CharsLDHUnderscore: nil}},
+		{name255 + "a", map[CharRule]error{CharsAny: ErrNameTooLong, CharsLDH: ErrNameTooLong, CharsLDHUnderscore: ErrNameTooLong}},
+		{"dom..example.com", map[CharRule]error{CharsAny: ErrEmptySublabel, CharsLDH: ErrEmptySublabel, CharsLDHUnderscore: ErrEmptySublabel}},
+		{"a.*.example.com", map[CharRule]error{CharsAny: ErrWildcardNotAtStart, CharsLDH: ErrWildcardNotAtStart, CharsLDHUnderscore: ErrWildcardNotAtStart}},
+	}
+
+	lax := New(testkey)
+
+	for rule := CharsAny; rule <= CharsLDHUnderscore; rule++ {
+		h := New(testkey, WithStrict(rule))
+
+		for _, c := range checks {
+			experr := c.Rules[rule]
+
+			o, err := h.Hash(c.Input, origindomain, NoCallback)
+			if !errors.Is(err, experr) {
+				t.Errorf("%s: Expected error %v for %q, got %q (%v)", rule, experr, c.Input, o, err)
+				continue
+			}
+
+			// Valid names hash the same as without strict validation
+			if err == nil {
+				exp, _ := lax.Hash(c.Input, origindomain, NoCallback)
+				if o != exp {
+					t.Errorf("%s: Expected %q for %q, got %q", rule, exp, c.Input, o)
+				}
+			}
+
+			var verr *ValidationError
+			if errors.As(err, &verr) && o != "" {
+				t.Errorf("%s: Expected no output for %q, got %q", rule, c.Input, o)
+			}
+		}
+	}
+
+	// The details of the violation
+	h := New(testkey, WithStrict(CharsLDH))
+
+	_, err := h.Hash("www._dmarc.example.com", origindomain, NoCallback)
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Err != ErrInvalidCharacter || verr.Label != "_dmarc" || verr.Offset != 4 {
+		t.Errorf("Unexpected error %#v", err)
+	}
+
+	return
+}
+
+// TestParseCharRule checks that every rule parses back
+func TestParseCharRule(t *testing.T) {
+	for _, r := range []CharRule{CharsAny, CharsLDH, CharsLDHUnderscore} {
+		pr, err := ParseCharRule(r.String())
+		if err != nil || pr != r {
+			t.Errorf("Expected %s, got %s (%v)", r, pr, err)
+		}
+	}
+
+	if _, err := ParseCharRule("ascii"); !errors.Is(err, ErrInvalidCharRule) {
+		t.Errorf("Expected error %s, got: %v", ErrInvalidCharRule, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected New to panic for an unknown rule")
+		}
+	}()
+
+	New(testkey, WithStrict(CharRule(42)))
+
+	return
+}
@@ -0,0 +1,144 @@
+package hashedrpz
+
+// Hashing of names in DNS wire format (length-prefixed labels), as held by resolvers.
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidWireName is returned when a name in wire format is malformed
+var ErrInvalidWireName = errors.New("Invalid wire format name")
+
+// maxWireNameLen is the maximum length of a name in wire format including the root label (RFC1035)
+const maxWireNameLen = 255
+
+// maxWireLabelLen is the maximum length of a label in wire format (RFC1035)
+const maxWireLabelLen = 63
+
+// wireLen returns the length in wire format (including the root label) of a name in presentation format,
+// a trailing dot is optional. The name is expected to be valid, escapes count as a single octet.
+func wireLen(name []byte) int {
+	if l := len(name); l > 0 && name[l-1] == '.' && !isEscaped(name, l-1) {
+		name = name[:l-1]
+	}
+
+	// Every character and escape is an octet, the dots become the length octets
+	// of the labels after them, plus the length octet of the first label and the root label.
+	n := 2
+
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' {
+			i++
+
+			if i < len(name) && isDigit(name[i]) {
+				i += 2
+			}
+		}
+
+		n++
+	}
+
+	return n
+}
+
+// appendPresentation appends the canonical presentation form (see appendCanonical)
+// of the name in wire format to dst.
+//
+// The name has to be uncompressed and end with the root label. A label that only consists
+// of an asterisk is the wildcard label (RFC4592), an asterisk inside another label is escaped.
+func appendPresentation(dst []byte, name []byte) ([]byte, error) {
+	if len(name) > maxWireNameLen {
+		return dst, fmt.Errorf("%w: longer than %d bytes", ErrInvalidWireName, maxWireNameLen)
+	}
+
+	start := len(dst)
+
+	for off := 0; off < len(name); {
+		l := int(name[off])
+		off++
+
+		// The root label ends the name
+		if l == 0 {
+			if off != len(name) {
+				return dst, fmt.Errorf("%w: data after the root label", ErrInvalidWireName)
+			}
+
+			return dst, nil
+		}
+
+		// Compression pointers (0xC0) and the extended label types also end up here
+		if l > maxWireLabelLen {
+			return dst, fmt.Errorf("%w: label longer than %d bytes (or compressed)", ErrInvalidWireName, maxWireLabelLen)
+		}
+
+		if off+l > len(name) {
+			return dst, fmt.Errorf("%w: truncated label", ErrInvalidWireName)
+		}
+
+		if len(dst) != start {
+			dst = append(dst, '.')
+		}
+
+		label := name[off : off+l]
+		off += l
+
+		if l == 1 && label[0] == '*' {
+			dst = append(dst, '*')
+			continue
+		}
+
+		for _, c := range label {
+			if c == '.' || c == '\\' || c == '*' {
+				dst = append(dst, '\\')
+			}
+
+			dst = append(dst, c)
+		}
+	}
+
+	return dst, fmt.Errorf("%w: missing root label", ErrInvalidWireName)
+}
+
+// HashWire is Hash for a name in DNS wire format (thus ```\x03www\x07example\x03com\x00```),
+// avoiding the conversion to a presentation string for every query.
+//
+// The name has to be uncompressed and end with the root label, the length of the labels
+// and the name are validated, an error wrapping ErrInvalidWireName is returned when invalid.
+//
+// The output is identical to that of Hash for the equivalent presentation name
+// (see FormatName), thus a dot inside a label is hashed as ```\.``` and the
+// subdomain passed to the callback is in that same presentation form.
+//
+// Only the root label (thus the root) results in ErrEmptyLabel, see Hash for the other errors.
+func (h *HashedRPZ) HashWire(name []byte, origindomain string, callback HashCallback) (final string, err error) {
+	hs := h.get()
+	defer h.put(hs)
+
+	hs.wire, err = appendPresentation(hs.wire[:0], name)
+	if err != nil {
+		return
+	}
+
+	start, err := hs.hash(hs.wire, []byte(origindomain), wrapCallback(callback))
+	final, err = string(hs.final[start:]), hs.hashError(err)
+
+	return
+}
+
+// AppendHashWire is the allocation-free variant of HashWire, see AppendHash.
+func (h *HashedRPZ) AppendHashWire(dst []byte, name []byte, origindomain []byte) ([]byte, error) {
+	hs := h.get()
+	defer h.put(hs)
+
+	var err error
+
+	hs.wire, err = appendPresentation(hs.wire[:0], name)
+	if err != nil {
+		return dst, err
+	}
+
+	start, err := hs.hash(hs.wire, origindomain, nil)
+
+	return append(dst, hs.final[start:]...), err
+}
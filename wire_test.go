@@ -0,0 +1,138 @@
+package hashedrpz
+
+// Tests for hashing names in wire format
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// toWire encodes the labels in wire format, without any checks
+func toWire(labels [][]byte) (wire []byte) {
+	for _, label := range labels {
+		wire = append(wire, byte(len(label)))
+		wire = append(wire, label...)
+	}
+
+	return append(wire, 0)
+}
+
+// TestHashWire checks that HashWire results in the same output as Hash on the presentation name
+func TestHashWire(t *testing.T) {
+	h := New(testkey)
+
+	for _, tt := range append(append([]htest{}, tests...), escapetests...) {
+		labels, err := ParseName(tt.Input)
+		if err != nil || len(labels) == 0 {
+			continue
+		}
+
+		wire := toWire(labels)
+		name := FormatName(labels)
+
+		o, err := h.HashWire(wire, origindomain, NoCallback)
+
+		if len(wire) > maxWireNameLen {
+			if !errors.Is(err, ErrInvalidWireName) {
+				t.Errorf("Expected error %s for %q, got %q (%v)", ErrInvalidWireName, name, o, err)
+			}
+
+			continue
+		}
+
+		exp, experr := h.Hash(name, origindomain, NoCallback)
+		if !sameError(err, experr) || o != exp {
+			t.Errorf("Expected %q (%v) for %q but got: %q (%v)", exp, experr, name, o, err)
+		}
+	}
+
+	return
+}
+
+// TestHashWireVectors checks the wire format specific cases
+func TestHashWireVectors(t *testing.T) {
+	h := New(testkey)
+
+	long := []byte{}
+	for i := 0; i < 4; i++ {
+		long = append(long, 63)
+		long = append(long, []byte(strings.Repeat("a", 63))...)
+	}
+	long = append(long, 0)
+
+	checks := []struct {
+		Name   string
+		Output string
+		Error  error
+	}{
+		{"\x03www\x07example\x03com\x00", "qtr7pq8.slhf50h8dgst0.8r4m02g", nil},
+		{"\x03a.b\x07example\x03com\x00", "kt64v3g.slhf50h8dgst0.8r4m02g", nil},
+		{"\x01*\x07example\x03com\x00", "*.slhf50h8dgst0.8r4m02g", nil},
+		{"\x01*\x00", "*.", nil},
+		{"\x03a\\b\x07example\x03com\x00", "ne9t0lg.slhf50h8dgst0.8r4m02g", nil},
+		{"\x01x\x01*\x07example\x03com\x00", "slhf50h8dgst0.8r4m02g", ErrWildcardNotAtStart},
+		{"\x00", "", ErrEmptyLabel},
+		{"", "", ErrInvalidWireName},
+		{"\x03www\x07example\x03com", "", ErrInvalidWireName},
+		{"\x03www\x07example\x03co", "", ErrInvalidWireName},
+		{"\x03www\xc0\x0c", "", ErrInvalidWireName},
+		{"\x03www\x00\x03com\x00", "", ErrInvalidWireName},
+		{"\x40" + strings.Repeat("a", 64) + "\x00", "", ErrInvalidWireName},
+		{string(long), "", ErrInvalidWireName},
+	}
+
+	for _, c := range checks {
+		o, err := h.HashWire([]byte(c.Name), origindomain, NoCallback)
+		if !errors.Is(err, c.Error) || o != c.Output {
+			t.Errorf("Expected %q (%v) for %q but got: %q (%v)", c.Output, c.Error, c.Name, o, err)
+		}
+
+		ao, aerr := h.AppendHashWire(nil, []byte(c.Name), []byte(origindomain))
+		if !errors.Is(aerr, c.Error) || string(ao) != o {
+			t.Errorf("Expected AppendHashWire to return %q (%v) for %q but got: %q (%v)", o, err, c.Name, ao, aerr)
+		}
+	}
+
+	return
+}
+
+// TestAppendHashWireAllocs checks that AppendHashWire does not allocate
+func TestAppendHashWireAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items under the race detector, thus allocates")
+	}
+
+	h := New(testkey)
+
+	dst := make([]byte, 0, 512)
+	name := []byte("\x03www\x07example\x03com\x00")
+	origin := []byte(origindomain)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		dst, _ = h.AppendHashWire(dst[:0], name, origin)
+	})
+
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %f", allocs)
+	}
+
+	return
+}
+
+// BenchmarkAppendHashWire benchmarks hashing a wire format name as a resolver would
+func BenchmarkAppendHashWire(b *testing.B) {
+	h := New(testkey)
+
+	dst := make([]byte, 0, 512)
+	name := []byte("\x03www\x07example\x03com\x00")
+	origin := []byte(origindomain)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		dst, _ = h.AppendHashWire(dst[:0], name, origin)
+	}
+
+	return
+}